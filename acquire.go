@@ -0,0 +1,102 @@
+package ocsql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opencensus.io/tag"
+)
+
+// acquireKey is the context key of the acquireMarker.
+type acquireKey struct{}
+
+// acquireMarker marks the start of a call on a *sql.DB. The acquire latency
+// is only measured when the first connection receives the call, even if the
+// context is reused afterwards. Connections receiving the call later on are
+// counted as retries, as database/sql retries calls failing with
+// driver.ErrBadConn on another connection.
+type acquireMarker struct {
+	start time.Time
+
+	mu    sync.Mutex
+	wait  time.Duration
+	conns int64
+	last  *ocConn
+}
+
+// receive registers the receipt of the call by conn. It returns the acquire
+// latency and whether conn is the first connection receiving the call.
+func (m *acquireMarker) receive(conn *ocConn) (wait time.Duration, first bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn == m.last {
+		return m.wait, false
+	}
+	m.last = conn
+	m.conns++
+	if m.conns == 1 {
+		m.wait = time.Since(m.start)
+		return m.wait, true
+	}
+	return m.wait, false
+}
+
+// summary returns the number of connections which received the call and the
+// latency of acquiring the first connection.
+func (m *acquireMarker) summary() (conns int64, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.conns, m.wait
+}
+
+// WithAcquireStart returns a copy of ctx marking the start of a call on a
+// *sql.DB. The time between the mark and the moment the wrapped connection
+// receives the call, which is mostly spent waiting for a connection from the
+// pool, is recorded as the acquire latency. The returned context is to be
+// passed to the call right away:
+//
+//	rows, err := db.QueryContext(ocsql.WithAcquireStart(ctx), query)
+func WithAcquireStart(ctx context.Context) context.Context {
+	ctx, _ = withAcquireMarker(ctx)
+	return ctx
+}
+
+// withAcquireMarker is like WithAcquireStart but also returns the marker.
+func withAcquireMarker(ctx context.Context) (context.Context, *acquireMarker) {
+	m := &acquireMarker{start: time.Now()}
+	return context.WithValue(ctx, acquireKey{}, m), m
+}
+
+// recordAcquire registers the receipt of the call by conn with the marker in
+// ctx, if any. On receipt by the first connection the time since the start of
+// the call is recorded as the latency of acquiring a connection. Acquisitions
+// taking SlowAcquireThreshold or longer are annotated to the span in ctx.
+func recordAcquire(ctx context.Context, conn *ocConn) {
+	m, ok := ctx.Value(acquireKey{}).(*acquireMarker)
+	if !ok {
+		return
+	}
+	latency, first := m.receive(conn)
+	if !first {
+		return
+	}
+	options := conn.options
+	latencyMs := float64(latency.Nanoseconds()) / 1e6
+
+	recorderFor(options).Record(ctx, append([]tag.Mutator{
+		tag.Insert(GoSQLInstance, options.InstanceName),
+	}, contextTags(ctx, options)...), MeasureAcquireLatencyMs.M(latencyMs))
+
+	if options.SlowAcquireThreshold <= 0 || latency < options.SlowAcquireThreshold {
+		return
+	}
+	if span := tracerFor(options).FromContext(ctx); span != nil {
+		span.Annotate([]Attribute{
+			float64Attr("sql.acquire_latency_ms", latencyMs),
+		}, fmt.Sprintf("ocsql: waited %s for a connection", latency))
+	}
+}
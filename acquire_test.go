@@ -0,0 +1,46 @@
+package ocsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestAcquireLatency(t *testing.T) {
+	if err := view.Register(SQLClientAcquireLatencyView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientAcquireLatencyView)
+
+	tracer := &recordingTracer{}
+	conn := WrapConn(stubConn{}, WithTracer(tracer), WithSlowAcquireThreshold(time.Millisecond))
+
+	ctx, parent := tracer.StartSpan(context.Background(), "parent")
+	if _, err := conn.(*ocConn).ExecContext(ctx, "UPDATE t SET a = 1", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx = WithAcquireStart(ctx)
+	time.Sleep(2 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err := conn.(*ocConn).ExecContext(ctx, "UPDATE t SET a = 1", nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if annotations := parent.(*recordedSpan).annotations; len(annotations) != 1 {
+		t.Errorf("want a single slow acquisition annotation, have: %v", annotations)
+	}
+	rows, err := view.RetrieveData(SQLClientAcquireLatencyView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("want a single row, have: %+v", rows)
+	}
+	if data := rows[0].Data.(*view.DistributionData); data.Count != 1 || data.Min < 2 {
+		t.Errorf("want a single acquisition of at least 2ms, have: %+v", data)
+	}
+}
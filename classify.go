@@ -0,0 +1,183 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+)
+
+// ErrorClassifier maps errors returned from SQL methods to a value with
+// bounded cardinality, which is used as the GoSQLError stats tag.
+type ErrorClassifier func(err error) string
+
+// The following error classes are returned by the error classifiers of this
+// package.
+const (
+	ErrorClassCanceled   = "canceled"
+	ErrorClassDeadline   = "deadline"
+	ErrorClassNoRows     = "no_rows"
+	ErrorClassTxDone     = "tx_done"
+	ErrorClassConnDone   = "conn_done"
+	ErrorClassErrSkip    = "err_skip"
+	ErrorClassConstraint = "constraint"
+	ErrorClassSyntax     = "syntax"
+	ErrorClassUnknown    = "unknown"
+)
+
+// DefaultErrorClassifier classifies the errors defined by the context,
+// database/sql and database/sql/driver packages. All other errors are
+// classified as ErrorClassUnknown.
+func DefaultErrorClassifier(err error) string {
+	class := ErrorClassUnknown
+	walkErrors(err, func(err error) bool {
+		switch err {
+		case context.Canceled:
+			class = ErrorClassCanceled
+		case context.DeadlineExceeded:
+			class = ErrorClassDeadline
+		case sql.ErrNoRows:
+			class = ErrorClassNoRows
+		case sql.ErrTxDone:
+			class = ErrorClassTxDone
+		case errConnDone, driver.ErrBadConn:
+			class = ErrorClassConnDone
+		case driver.ErrSkip:
+			class = ErrorClassErrSkip
+		default:
+			return false
+		}
+		return true
+	})
+	return class
+}
+
+// PostgresErrorClassifier extends DefaultErrorClassifier by classifying
+// errors holding a SQLSTATE code, like the ones returned by the lib/pq and
+// jackc/pgx drivers. Integrity constraint violations (class 23) are classified
+// as ErrorClassConstraint and syntax errors or access rule violations
+// (class 42) as ErrorClassSyntax.
+func PostgresErrorClassifier(err error) string {
+	class := ""
+	walkErrors(err, func(err error) bool {
+		code := sqlState(err)
+		switch {
+		case len(code) != 5:
+			return false
+		case code == "57014":
+			class = ErrorClassCanceled
+		case code[:2] == "23":
+			class = ErrorClassConstraint
+		case code[:2] == "42":
+			class = ErrorClassSyntax
+		case code[:2] == "08":
+			class = ErrorClassConnDone
+		default:
+			class = ErrorClassUnknown
+		}
+		return true
+	})
+	if class == "" {
+		return DefaultErrorClassifier(err)
+	}
+	return class
+}
+
+// mysqlErrorClasses maps MySQL server error numbers to error classes.
+var mysqlErrorClasses = map[uint64]string{
+	1048: ErrorClassConstraint, // ER_BAD_NULL_ERROR
+	1062: ErrorClassConstraint, // ER_DUP_ENTRY
+	1169: ErrorClassConstraint, // ER_DUP_UNIQUE
+	1216: ErrorClassConstraint, // ER_NO_REFERENCED_ROW
+	1217: ErrorClassConstraint, // ER_ROW_IS_REFERENCED
+	1451: ErrorClassConstraint, // ER_ROW_IS_REFERENCED_2
+	1452: ErrorClassConstraint, // ER_NO_REFERENCED_ROW_2
+	1557: ErrorClassConstraint, // ER_FOREIGN_DUPLICATE_KEY
+	1586: ErrorClassConstraint, // ER_DUP_ENTRY_WITH_KEY_NAME
+	3819: ErrorClassConstraint, // ER_CHECK_CONSTRAINT_VIOLATED
+	1064: ErrorClassSyntax,     // ER_PARSE_ERROR
+	1149: ErrorClassSyntax,     // ER_SYNTAX_ERROR
+	1317: ErrorClassCanceled,   // ER_QUERY_INTERRUPTED
+	3024: ErrorClassDeadline,   // ER_QUERY_TIMEOUT
+}
+
+// MySQLErrorClassifier extends DefaultErrorClassifier by classifying errors
+// holding a MySQL server error number in their Number field, like the
+// *mysql.MySQLError returned by the go-sql-driver/mysql driver.
+func MySQLErrorClassifier(err error) string {
+	class := ""
+	walkErrors(err, func(err error) bool {
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct {
+			return false
+		}
+		f := v.FieldByName("Number")
+		switch f.Kind() {
+		case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return false
+		}
+		var ok bool
+		if class, ok = mysqlErrorClasses[f.Uint()]; !ok {
+			class = ErrorClassUnknown
+		}
+		return true
+	})
+	if class == "" {
+		return DefaultErrorClassifier(err)
+	}
+	return class
+}
+
+// sqlState returns the SQLSTATE code of err if available.
+func sqlState(err error) string {
+	if s, ok := err.(interface{ SQLState() string }); ok {
+		return s.SQLState()
+	}
+	v := reflect.Indirect(reflect.ValueOf(err))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if f := v.FieldByName("Code"); f.Kind() == reflect.String {
+		return f.String()
+	}
+	return ""
+}
+
+// maxErrorDepth limits the number of errors visited by walkErrors, guarding
+// it against errors which wrap themselves.
+const maxErrorDepth = 32
+
+// walkErrors calls fn for err and each error it wraps, depth first, until fn
+// returns true. Errors wrapping multiple errors, like the ones returned by
+// errors.Join, have their errors walked in order.
+func walkErrors(err error, fn func(err error) bool) {
+	budget := maxErrorDepth
+	walkError(err, fn, &budget)
+}
+
+// walkError implements walkErrors, reporting whether fn returned true.
+func walkError(err error, fn func(err error) bool, budget *int) bool {
+	for ; err != nil && *budget > 0; *budget-- {
+		if fn(err) {
+			return true
+		}
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			*budget--
+			for _, err := range e.Unwrap() {
+				if walkError(err, fn, budget) {
+					return true
+				}
+			}
+			return false
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Cause() error }:
+			err = e.Cause()
+		default:
+			return false
+		}
+	}
+	return false
+}
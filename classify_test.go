@@ -0,0 +1,63 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type wrappedError struct{ err error }
+
+func (e wrappedError) Error() string { return fmt.Sprintf("wrapped: %v", e.err) }
+func (e wrappedError) Unwrap() error { return e.err }
+
+// joinedError wraps multiple errors like the errors returned by errors.Join.
+type joinedError []error
+
+func (e joinedError) Error() string   { return fmt.Sprint([]error(e)) }
+func (e joinedError) Unwrap() []error { return e }
+
+type pgError struct{ Code string }
+
+func (e *pgError) Error() string { return "pq: " + e.Code }
+
+type mysqlError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlError) Error() string { return e.Message }
+
+func TestErrorClassifiers(t *testing.T) {
+	tests := []struct {
+		classifier ErrorClassifier
+		err        error
+		want       string
+	}{
+		{DefaultErrorClassifier, context.Canceled, ErrorClassCanceled},
+		{DefaultErrorClassifier, wrappedError{context.DeadlineExceeded}, ErrorClassDeadline},
+		{DefaultErrorClassifier, sql.ErrNoRows, ErrorClassNoRows},
+		{DefaultErrorClassifier, errors.New("duplicate key value 42"), ErrorClassUnknown},
+		{DefaultErrorClassifier, &pgError{"23505"}, ErrorClassUnknown},
+		{PostgresErrorClassifier, &pgError{"23505"}, ErrorClassConstraint},
+		{PostgresErrorClassifier, wrappedError{&pgError{"42601"}}, ErrorClassSyntax},
+		{PostgresErrorClassifier, &pgError{"40001"}, ErrorClassUnknown},
+		{PostgresErrorClassifier, sql.ErrTxDone, ErrorClassTxDone},
+		{MySQLErrorClassifier, &mysqlError{1062, "Duplicate entry 'x'"}, ErrorClassConstraint},
+		{MySQLErrorClassifier, wrappedError{&mysqlError{1064, "parse error"}}, ErrorClassSyntax},
+		{MySQLErrorClassifier, &mysqlError{1205, "lock wait timeout"}, ErrorClassUnknown},
+		{MySQLErrorClassifier, context.Canceled, ErrorClassCanceled},
+		{DefaultErrorClassifier, joinedError{errors.New("rollback failed"), wrappedError{sql.ErrTxDone}}, ErrorClassTxDone},
+		{PostgresErrorClassifier, wrappedError{joinedError{errDummy, &pgError{"23505"}}}, ErrorClassConstraint},
+		{MySQLErrorClassifier, joinedError{joinedError{errDummy}, &mysqlError{1064, "parse error"}}, ErrorClassSyntax},
+		{DefaultErrorClassifier, joinedError{errDummy}, ErrorClassUnknown},
+	}
+
+	for _, test := range tests {
+		if have := test.classifier(test.err); have != test.want {
+			t.Errorf("classify(%v) want: %s, have: %s", test.err, test.want, have)
+		}
+	}
+}
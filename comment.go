@@ -0,0 +1,142 @@
+package ocsql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// SQLCommenter configures the propagation of trace context into sql queries
+// by appending a comment following the sqlcommenter specification
+// (https://google.github.io/sqlcommenter/spec/), e.g.:
+//
+//	SELECT * FROM users /*application='api',route='%2Fusers',traceparent='00-...-01'*/
+//
+// Queries already holding a comment are left untouched.
+type SQLCommenter struct {
+	// Application, if set, is added as the application key.
+	Application string
+
+	// ContextKeys maps sqlcommenter keys (e.g. route) to the context keys
+	// holding their values. Values of type string or fmt.Stringer are added to
+	// the comment, others are ignored.
+	ContextKeys map[string]interface{}
+
+	// Prepared, if set to true, will also comment prepared statements and
+	// queries with arguments. As the comment differs for each trace, it
+	// defeats the prepared statement caching done by most drivers and
+	// databases for these statements.
+	Prepared bool
+}
+
+// commenterInterceptors returns the interceptors appending the sqlcommenter
+// comment to the queries sent to the parent driver.
+func commenterInterceptors(o TraceOptions) Interceptors {
+	return Interceptors{
+		Exec: func(ctx context.Context, call *Call, next ExecFunc) (driver.Result, error) {
+			if call.Method != "go.sql.exec" {
+				return next(ctx, call)
+			}
+			c := *call
+			c.Query = commentQuery(ctx, call.Query, len(call.Args) > 0, o)
+			return next(ctx, &c)
+		},
+		Query: func(ctx context.Context, call *Call, next QueryFunc) (driver.Rows, error) {
+			if call.Method != "go.sql.query" {
+				return next(ctx, call)
+			}
+			c := *call
+			c.Query = commentQuery(ctx, call.Query, len(call.Args) > 0, o)
+			return next(ctx, &c)
+		},
+		Prepare: func(ctx context.Context, call *Call, next PrepareFunc) (driver.Stmt, error) {
+			c := *call
+			c.Query = commentQuery(ctx, call.Query, true, o)
+			return next(ctx, &c)
+		},
+	}
+}
+
+// commentQuery returns query with the sqlcommenter comment built from ctx
+// appended. Prepared identifies prepared statements and queries with
+// arguments.
+func commentQuery(ctx context.Context, query string, prepared bool, options TraceOptions) string {
+	c := options.SQLCommenter
+	if c == nil || (prepared && !c.Prepared) {
+		return query
+	}
+
+	tags := make(map[string]string, len(c.ContextKeys)+3)
+	if c.Application != "" {
+		tags["application"] = c.Application
+	}
+	for key, ctxKey := range c.ContextKeys {
+		switch v := ctx.Value(ctxKey).(type) {
+		case string:
+			tags[key] = v
+		case fmt.Stringer:
+			tags[key] = v.String()
+		}
+	}
+	if span := tracerFor(options).FromContext(ctx); span != nil {
+		sc := span.SpanContext()
+		flags := "00"
+		if sc.Sampled {
+			flags = "01"
+		}
+		tags["traceparent"] = "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+		if sc.Tracestate != "" {
+			tags["tracestate"] = sc.Tracestate
+		}
+	}
+	if len(tags) == 0 || hasComment(query, options.Dialect) {
+		return query
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	// keep a trailing semicolon terminating the statement
+	stmt := strings.TrimRight(query, " \t\r\n")
+	terminated := strings.HasSuffix(stmt, ";")
+	buf.WriteString(strings.TrimSuffix(stmt, ";"))
+	buf.WriteString(" /*")
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		// url encoding escapes all quotes and comment delimiters
+		buf.WriteString(url.PathEscape(key))
+		buf.WriteString("='")
+		buf.WriteString(url.PathEscape(tags[key]))
+		buf.WriteByte('\'')
+	}
+	buf.WriteString("*/")
+	if terminated {
+		buf.WriteByte(';')
+	}
+	return buf.String()
+}
+
+// hasComment reports whether query holds a comment.
+func hasComment(query string, dialect Dialect) bool {
+	if !strings.Contains(query, "--") && !strings.Contains(query, "/*") &&
+		(dialect != DialectMySQL || !strings.Contains(query, "#")) {
+		return false
+	}
+	for _, t := range tokenize(query, dialect) {
+		if t.typ == tokenComment {
+			return true
+		}
+	}
+	return false
+}
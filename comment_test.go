@@ -0,0 +1,53 @@
+package ocsql
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+type routeKey struct{}
+
+func TestCommentQuery(t *testing.T) {
+	ctx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+	ctx = context.WithValue(ctx, routeKey{}, "/users/{id}'*/ x")
+
+	sc := span.SpanContext()
+	traceparent := "traceparent='00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-01'"
+
+	options := TraceOptions{SQLCommenter: &SQLCommenter{
+		Application: "api",
+		ContextKeys: map[string]interface{}{"route": routeKey{}},
+	}}
+
+	tests := []struct {
+		query    string
+		prepared bool
+		want     string
+	}{
+		{
+			"SELECT * FROM users",
+			false,
+			"SELECT * FROM users /*application='api',route='%2Fusers%2F%7Bid%7D%27%2A%2F%20x'," + traceparent + "*/",
+		},
+		{
+			"DELETE FROM users; \n",
+			false,
+			"DELETE FROM users /*application='api',route='%2Fusers%2F%7Bid%7D%27%2A%2F%20x'," + traceparent + "*/;",
+		},
+		{"SELECT * FROM users /* hint */", false, "SELECT * FROM users /* hint */"},
+		{"SELECT * FROM users WHERE id = ?", true, "SELECT * FROM users WHERE id = ?"},
+	}
+
+	for _, test := range tests {
+		if have := commentQuery(ctx, test.query, test.prepared, options); have != test.want {
+			t.Errorf("commentQuery(%q)\nwant: %q\nhave: %q", test.query, test.want, have)
+		}
+	}
+
+	if have := commentQuery(ctx, "SELECT 1", false, TraceOptions{}); have != "SELECT 1" {
+		t.Errorf("want query untouched without SQLCommenter, have: %q", have)
+	}
+}
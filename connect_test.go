@@ -0,0 +1,124 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+)
+
+type stubConn struct{ driver.Conn }
+
+func (stubConn) Close() error { return nil }
+
+func (stubConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+type stubConnector struct{ err error }
+
+func (c stubConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return stubConn{}, nil
+}
+
+func (stubConnector) Driver() driver.Driver { return nil }
+
+func TestConnect(t *testing.T) {
+	if err := view.Register(SQLClientConnectErrorsView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientConnectErrorsView)
+
+	tracer := &recordingTracer{}
+	ctx, _ := tracer.StartSpan(context.Background(), "parent")
+
+	connector := WrapConnector(stubConnector{}, WithConnect(true), WithTracer(tracer))
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := conn.(*ocConn); !ok {
+		t.Errorf("want wrapped connection, have: %T", conn)
+	}
+	if err = conn.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tracer.spans) != 2 {
+		t.Fatalf("want parent and connect span only, have: %d spans", len(tracer.spans))
+	}
+	if span := tracer.spans[1]; span.name != "sql:connect" || !span.ended || span.status.Code != trace.StatusCodeOK {
+		t.Errorf("want ended sql:connect span, have: %+v", span)
+	}
+
+	connector = WrapConnector(stubConnector{err: errDummy},
+		WithConnect(true), WithTracer(tracer), WithInstanceName("connect-test"),
+	)
+	if _, err = connector.Connect(ctx); err != errDummy {
+		t.Fatalf("want error %v, have: %v", errDummy, err)
+	}
+	if span := tracer.spans[2]; span.name != "sql:connect" || span.status.Code != trace.StatusCodeUnknown {
+		t.Errorf("want failed sql:connect span, have: %+v", span)
+	}
+
+	rows, err := view.RetrieveData(SQLClientConnectErrorsView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.CountData).Value != 1 {
+		t.Errorf("want a single connect error, have: %+v", rows)
+	}
+}
+
+func TestConnUsage(t *testing.T) {
+	if err := view.Register(SQLClientConnStatementsView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientConnStatementsView)
+
+	conn := WrapConn(stubConn{}).(*ocConn)
+	for i := 0; i < 3; i++ {
+		if _, err := conn.ExecContext(context.Background(), "UPDATE t SET a = 1", nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := conn.Query("SELECT 1", nil); err != driver.ErrSkip {
+		t.Fatalf("want error %v, have: %v", driver.ErrSkip, err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rows, err := view.RetrieveData(SQLClientConnStatementsView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("want a single row, have: %+v", rows)
+	}
+	if data := rows[0].Data.(*view.DistributionData); data.Count != 1 || data.Mean != 3 {
+		t.Errorf("want a single connection executing 3 statements, have: %+v", data)
+	}
+}
+
+func TestConnUsageRows(t *testing.T) {
+	conn := WrapConn(queryConn{rows: stubRows{}}).(*ocConn)
+	rows, err := conn.QueryContext(context.Background(), "SELECT 1", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, busy := conn.usage.summary(); busy != 0 {
+		t.Errorf("want connection busy until the rows are closed, have: %s busy", busy)
+	}
+	time.Sleep(10 * time.Millisecond)
+	_ = rows.Close()
+
+	if _, statements, busy := conn.usage.summary(); statements != 1 || busy < 10*time.Millisecond {
+		t.Errorf("want a single statement keeping the connection busy while reading rows, have: %d statements, %s busy", statements, busy)
+	}
+}
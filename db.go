@@ -0,0 +1,184 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+)
+
+// DB wraps a *sql.DB and creates a span around each call on the database.
+// The spans created by a driver wrapped by ocsql nest under it, so work done
+// by database/sql itself, such as waiting for a free connection and retrying
+// calls failing with driver.ErrBadConn, becomes visible. The number of
+// retries and the time spent waiting for a connection are only known if the
+// driver of the database is wrapped by ocsql.
+//
+// Spans of Query calls end when the call returns, the iteration of the rows
+// is covered by the sql:rows span of the driver. Spans of QueryRow calls end
+// when the Row is scanned.
+//
+// DB is not a complete drop-in replacement of *sql.DB: to record the errors
+// returned by Scan, QueryRow and QueryRowContext return a *Row of this package
+// rather than a *sql.Row. Code requiring a *sql.Row can call the methods of
+// the embedded *sql.DB, without the outer span.
+type DB struct {
+	*sql.DB
+	options TraceOptions
+}
+
+// NewDB returns db wrapped to create spans. If the driver of db is wrapped by
+// ocsql, the spans are configured by its TraceOptions, including the database
+// detected from the wrapped driver and its data source name, with options
+// applied over them. Otherwise only options configure the spans.
+func NewDB(db *sql.DB, options ...TraceOption) *DB {
+	o, ok := driverOptions(db)
+	if !ok {
+		return &DB{DB: db, options: newTraceOptions(db, options...)}
+	}
+	if len(options) > 0 {
+		// cap the slices to prevent options appending to them from modifying
+		// the options of the driver
+		o.DefaultAttributes = o.DefaultAttributes[:len(o.DefaultAttributes):len(o.DefaultAttributes)]
+		o.Interceptors = o.Interceptors[:len(o.Interceptors):len(o.Interceptors)]
+		for _, option := range options {
+			option(&o)
+		}
+		if o.QueryParams && !o.Query {
+			o.QueryParams = false
+		}
+		o.interceptors = buildChain(o)
+	}
+	return &DB{DB: db, options: o}
+}
+
+// driverOptions returns the TraceOptions of the driver of db if it is wrapped
+// by ocsql.
+func driverOptions(db *sql.DB) (TraceOptions, bool) {
+	d := db.Driver()
+	if w, ok := d.(struct{ driver.Driver }); ok {
+		d = w.Driver
+	}
+	od, ok := d.(ocDriver)
+	return od.options, ok
+}
+
+// PingContext verifies a connection to the database is still alive,
+// establishing a connection if necessary.
+func (db *DB) PingContext(ctx context.Context) (err error) {
+	ctx, end := db.startCall(ctx, "sql:db_ping", "", db.options.Ping)
+	defer func() { end(err) }()
+	return db.DB.PingContext(ctx)
+}
+
+// Ping verifies a connection to the database is still alive, establishing a
+// connection if necessary.
+func (db *DB) Ping() error {
+	return db.PingContext(context.Background())
+}
+
+// ExecContext executes a query without returning any rows.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	ctx, end := db.startCall(ctx, "sql:db_exec", query, true)
+	defer func() { end(err) }()
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+// Exec executes a query without returning any rows.
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query that returns rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	ctx, end := db.startCall(ctx, "sql:db_query", query, true)
+	defer func() { end(err) }()
+	return db.DB.QueryContext(ctx, query, args...)
+}
+
+// Query executes a query that returns rows.
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most one
+// row. The span of the call ends when the returned Row is scanned.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	ctx, end := db.startCall(ctx, "sql:db_query_row", query, true)
+	return &Row{row: db.DB.QueryRowContext(ctx, query, args...), end: end}
+}
+
+// QueryRow executes a query that is expected to return at most one row. The
+// span of the call ends when the returned Row is scanned.
+func (db *DB) QueryRow(query string, args ...interface{}) *Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// PrepareContext creates a prepared statement for later queries or
+// executions.
+func (db *DB) PrepareContext(ctx context.Context, query string) (stmt *sql.Stmt, err error) {
+	ctx, end := db.startCall(ctx, "sql:db_prepare", query, true)
+	defer func() { end(err) }()
+	return db.DB.PrepareContext(ctx, query)
+}
+
+// Prepare creates a prepared statement for later queries or executions.
+func (db *DB) Prepare(query string) (*sql.Stmt, error) {
+	return db.PrepareContext(context.Background(), query)
+}
+
+// BeginTx starts a transaction.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	ctx, end := db.startCall(ctx, "sql:db_begin", "", true)
+	defer func() { end(err) }()
+	return db.DB.BeginTx(ctx, opts)
+}
+
+// Begin starts a transaction.
+func (db *DB) Begin() (*sql.Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// startCall marks the start of a call on the database and, if enabled, starts
+// its span. The returned function ends the span, recording the retries, the
+// time spent waiting for a connection and err.
+func (db *DB) startCall(ctx context.Context, name, query string, enabled bool) (context.Context, func(error)) {
+	o := contextOptions(ctx, db.options)
+	if !enabled || o.SkipTracing || (!o.AllowRoot && tracerFor(o).FromContext(ctx) == nil) {
+		ctx, _ = withAcquireMarker(ctx)
+		return ctx, func(error) {}
+	}
+
+	ctx, span := startSpan(ctx, name, query, o)
+	if query != "" && o.Query {
+		span.AddAttributes(queryAttr(query, o))
+	}
+	ctx, m := withAcquireMarker(ctx)
+	return ctx, func(err error) {
+		if conns, wait := m.summary(); conns > 0 {
+			span.AddAttributes(
+				int64Attr("sql.retries", conns-1),
+				float64Attr("sql.pool_wait_ms", float64(wait.Nanoseconds())/1e6),
+			)
+		}
+		setSpanStatus(span, o, err)
+		span.End()
+	}
+}
+
+// Row is the result of a QueryRow call on a DB. It replaces *sql.Row to end
+// the span of the call with the outcome of Scan.
+type Row struct {
+	row *sql.Row
+	end func(error)
+}
+
+// Scan copies the columns of the row into the values pointed at by dest and
+// ends the span of the call, recording errors such as sql.ErrNoRows.
+func (r *Row) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	if r.end != nil {
+		r.end(err)
+		r.end = nil
+	}
+	return err
+}
@@ -0,0 +1,8 @@
+// +build go1.15
+
+package ocsql
+
+// Err provides a way to check for query errors without calling Scan.
+func (r *Row) Err() error {
+	return r.row.Err()
+}
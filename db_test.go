@@ -0,0 +1,100 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"a"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+// flakyConnector returns a connection failing with driver.ErrBadConn on its
+// first connection.
+type flakyConnector struct{ conns *int }
+
+func (c flakyConnector) Connect(context.Context) (driver.Conn, error) {
+	*c.conns++
+	return flakyConn{bad: *c.conns == 1}, nil
+}
+
+func (flakyConnector) Driver() driver.Driver { return nil }
+
+type flakyConn struct {
+	stubConn
+	bad bool
+}
+
+func (c flakyConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	if c.bad {
+		return nil, driver.ErrBadConn
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (flakyConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return emptyRows{}, nil
+}
+
+func TestDB(t *testing.T) {
+	tracer := &recordingTracer{}
+	sqlDB := sql.OpenDB(WrapConnector(flakyConnector{conns: new(int)}, WithTracer(tracer)))
+	defer sqlDB.Close()
+	db := NewDB(sqlDB, WithTracer(tracer), WithAllowRoot(true))
+
+	if _, err := db.Exec("UPDATE t SET a = 1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var a int
+	if err := db.QueryRow("SELECT a FROM t").Scan(&a); err != sql.ErrNoRows {
+		t.Fatalf("want error %v, have: %v", sql.ErrNoRows, err)
+	}
+
+	var names []string
+	for _, span := range tracer.spans {
+		names = append(names, span.name)
+	}
+	want := []string{"sql:db_exec", "sql:exec", "sql:exec", "sql:db_query_row", "sql:query"}
+	if len(names) != len(want) {
+		t.Fatalf("want spans %v, have: %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("want spans %v, have: %v", want, names)
+		}
+	}
+
+	if span := tracer.spans[0]; span.attrs["sql.retries"] != int64(1) || span.status.Code != trace.StatusCodeOK {
+		t.Errorf("want successful exec after a single retry, have: %+v", span)
+	}
+	if span := tracer.spans[3]; !span.ended || span.attrs["sql.retries"] != int64(0) || span.status.Code != trace.StatusCodeNotFound {
+		t.Errorf("want query row span ended with not found status, have: %+v", span)
+	}
+}
+
+func TestNewDBDriverOptions(t *testing.T) {
+	sqlDB := sql.OpenDB(WrapConnector(flakyConnector{conns: new(int)},
+		WithInstanceName("users"), WithDatabase(Database{System: "postgresql"}),
+	))
+	defer sqlDB.Close()
+
+	if o := NewDB(sqlDB).options; o.InstanceName != "users" || o.Database.System != "postgresql" {
+		t.Errorf("want the options of the wrapped driver, have: %+v", o)
+	}
+	if o := NewDB(sqlDB, WithQuery(true)).options; o.InstanceName != "users" || !o.Query {
+		t.Errorf("want options applied over the ones of the wrapped driver, have: %+v", o)
+	}
+
+	plainDB := sql.OpenDB(flakyConnector{conns: new(int)})
+	defer plainDB.Close()
+	if o := NewDB(plainDB, WithQuery(true)).options; o.InstanceName != defaultInstanceName || !o.Query {
+		t.Errorf("want the provided options only, have: %+v", o)
+	}
+}
@@ -5,15 +5,143 @@ package ocsql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"sync"
 	"time"
 
 	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 )
 
 // RecordStats records database statistics for provided sql.DB at the provided
-// interval.
+// interval. The statistics are tagged with the default instance name; use a
+// StatsRecorder to record the statistics of multiple databases.
 func RecordStats(db *sql.DB, interval time.Duration) (fnStop func()) {
+	r := NewStatsRecorder()
+	_ = r.Register("default", db)
+	return r.Start(interval)
+}
+
+// StatsRecorder records the connection pool statistics of any number of
+// databases, each tagged with the instance name it was registered under.
+// Statistics are recorded at the interval of Start or on demand by calling
+// Record, e.g. from a metrics-export callback. Next to the cumulative
+// statistics, the pool pressure since the previous recording is derived.
+type StatsRecorder struct {
+	mu  sync.Mutex
+	dbs map[string]*recordedDB
+}
+
+// recordedDB is a database registered with a StatsRecorder.
+type recordedDB struct {
+	db   *sql.DB
+	tags []tag.Mutator
+	prev sql.DBStats
+}
+
+// NewStatsRecorder returns a StatsRecorder without registered databases.
+func NewStatsRecorder() *StatsRecorder {
+	return &StatsRecorder{dbs: make(map[string]*recordedDB)}
+}
+
+// Register adds db to the databases of which statistics are recorded. The
+// statistics are tagged with instanceName as GoSQLInstance and the provided
+// tags. An error is returned if instanceName is already registered.
+func (r *StatsRecorder) Register(instanceName string, db *sql.DB, tags ...tag.Mutator) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.dbs[instanceName]; ok {
+		return errors.New("unable to register stats, instance " + instanceName + " already taken")
+	}
+	r.dbs[instanceName] = &recordedDB{
+		db:   db,
+		tags: append([]tag.Mutator{tag.Insert(GoSQLInstance, instanceName)}, tags...),
+		prev: db.Stats(),
+	}
+	return nil
+}
+
+// Unregister stops recording the statistics of the database registered under
+// instanceName.
+func (r *StatsRecorder) Unregister(instanceName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.dbs, instanceName)
+}
+
+// Record synchronously records the current statistics of all registered
+// databases.
+func (r *StatsRecorder) Record(ctx context.Context) {
+	r.RecordWith(ctx, openCensusRecorder{})
+}
+
+// RecordWith synchronously records the current statistics of all registered
+// databases with recorder, e.g. to bridge them into another metrics system.
+func (r *StatsRecorder) RecordWith(ctx context.Context, recorder Recorder) {
+	type recording struct {
+		tags         []tag.Mutator
+		measurements []stats.Measurement
+	}
+
+	r.mu.Lock()
+	recordings := make([]recording, 0, len(r.dbs))
+	for _, db := range r.dbs {
+		dbStats := db.db.Stats()
+		recordings = append(recordings, recording{
+			tags:         db.tags,
+			measurements: dbStatsMeasurements(db.prev, dbStats),
+		})
+		db.prev = dbStats
+	}
+	r.mu.Unlock()
+
+	for _, rec := range recordings {
+		recorder.Record(ctx, rec.tags, rec.measurements...)
+	}
+}
+
+// dbStatsMeasurements returns the measurements of dbStats and of the pool
+// pressure derived from the change since prev.
+func dbStatsMeasurements(prev, dbStats sql.DBStats) []stats.Measurement {
+	var (
+		waitCount    = dbStats.WaitCount - prev.WaitCount
+		waitDuration = dbStats.WaitDuration - prev.WaitDuration
+		avgWaitMs    float64
+		saturated    int64
+	)
+	if waitCount > 0 {
+		avgWaitMs = float64(waitDuration.Nanoseconds()) / 1e6 / float64(waitCount)
+	}
+	if waitCount > 0 || (dbStats.MaxOpenConnections > 0 && dbStats.InUse >= dbStats.MaxOpenConnections) {
+		saturated = 1
+	}
+
+	measurements := []stats.Measurement{
+		MeasureOpenConnections.M(int64(dbStats.OpenConnections)),
+		MeasureIdleConnections.M(int64(dbStats.Idle)),
+		MeasureActiveConnections.M(int64(dbStats.InUse)),
+		MeasureWaitCount.M(dbStats.WaitCount),
+		MeasureWaitDuration.M(float64(dbStats.WaitDuration.Nanoseconds()) / 1e6),
+		MeasureIdleClosed.M(dbStats.MaxIdleClosed),
+		MeasureLifetimeClosed.M(dbStats.MaxLifetimeClosed),
+		MeasureIntervalWaitCount.M(waitCount),
+		MeasureAvgWaitDuration.M(avgWaitMs),
+		MeasureSaturated.M(saturated),
+	}
+	if dbStats.MaxOpenConnections > 0 {
+		// utilization is undefined for pools without connection limit
+		measurements = append(measurements, MeasureUtilization.M(
+			float64(dbStats.InUse)/float64(dbStats.MaxOpenConnections),
+		))
+	}
+	return measurements
+}
+
+// Start records the statistics of all registered databases at the provided
+// interval until the returned function is called.
+func (r *StatsRecorder) Start(interval time.Duration) (fnStop func()) {
 	var (
 		closeOnce sync.Once
 		ctx       = context.Background()
@@ -25,16 +153,7 @@ func RecordStats(db *sql.DB, interval time.Duration) (fnStop func()) {
 		for {
 			select {
 			case <-ticker.C:
-				dbStats := db.Stats()
-				stats.Record(ctx,
-					MeasureOpenConnections.M(int64(dbStats.OpenConnections)),
-					MeasureIdleConnections.M(int64(dbStats.Idle)),
-					MeasureActiveConnections.M(int64(dbStats.InUse)),
-					MeasureWaitCount.M(dbStats.WaitCount),
-					MeasureWaitDuration.M(float64(dbStats.WaitDuration.Nanoseconds())/1e6),
-					MeasureIdleClosed.M(dbStats.MaxIdleClosed),
-					MeasureLifetimeClosed.M(dbStats.MaxLifetimeClosed),
-				)
+				r.Record(ctx)
 			case <-done:
 				ticker.Stop()
 				return
@@ -0,0 +1,112 @@
+// +build go1.11
+
+package ocsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestStatsRecorder(t *testing.T) {
+	if err := view.Register(SQLClientOpenConnectionsView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientOpenConnectionsView)
+
+	var (
+		primary = sql.OpenDB(stubConnector{})
+		replica = sql.OpenDB(stubConnector{})
+	)
+	defer primary.Close()
+	defer replica.Close()
+	if err := primary.Ping(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := NewStatsRecorder()
+	if err := r.Register("primary", primary); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Register("replica", replica); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Register("replica", primary); err == nil {
+		t.Error("want error registering instance twice")
+	}
+	r.Record(context.Background())
+
+	rows, err := view.RetrieveData(SQLClientOpenConnectionsView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := map[string]float64{}
+	for _, row := range rows {
+		if len(row.Tags) != 1 || row.Tags[0].Key != GoSQLInstance {
+			t.Fatalf("want instance tag only, have: %v", row.Tags)
+		}
+		open[row.Tags[0].Value] = row.Data.(*view.LastValueData).Value
+	}
+	if len(open) != 2 || open["primary"] != 1 || open["replica"] != 0 {
+		t.Errorf("want open connections per instance, have: %v", open)
+	}
+
+	r.Unregister("replica")
+	if err := r.Register("replica", replica); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDBStatsMeasurements(t *testing.T) {
+	prev := sql.DBStats{WaitCount: 10, WaitDuration: 100 * time.Millisecond}
+	for _, tc := range []struct {
+		name        string
+		dbStats     sql.DBStats
+		waitCount   int64
+		avgWaitMs   float64
+		utilization float64
+		saturated   int64
+	}{
+		{
+			name:    "unlimited idle pool",
+			dbStats: sql.DBStats{WaitCount: 10, WaitDuration: 100 * time.Millisecond},
+		},
+		{
+			name: "waiting",
+			dbStats: sql.DBStats{
+				MaxOpenConnections: 4, InUse: 2, WaitCount: 14, WaitDuration: 300 * time.Millisecond,
+			},
+			waitCount: 4, avgWaitMs: 50, utilization: 0.5, saturated: 1,
+		},
+		{
+			name: "all in use",
+			dbStats: sql.DBStats{
+				MaxOpenConnections: 4, InUse: 4, WaitCount: 10, WaitDuration: 100 * time.Millisecond,
+			},
+			utilization: 1, saturated: 1,
+		},
+	} {
+		have := map[string]interface{}{}
+		for _, m := range dbStatsMeasurements(prev, tc.dbStats) {
+			have[m.Measure().Name()] = m.Value()
+		}
+		want := map[string]interface{}{
+			MeasureIntervalWaitCount.Name(): float64(tc.waitCount),
+			MeasureAvgWaitDuration.Name():   tc.avgWaitMs,
+			MeasureSaturated.Name():         float64(tc.saturated),
+		}
+		if tc.dbStats.MaxOpenConnections > 0 {
+			want[MeasureUtilization.Name()] = tc.utilization
+		} else if _, ok := have[MeasureUtilization.Name()]; ok {
+			t.Errorf("%s: want no utilization for unlimited pool", tc.name)
+		}
+		for name, value := range want {
+			if have[name] != value {
+				t.Errorf("%s: %s want: %v, have: %v", tc.name, name, value, have[name])
+			}
+		}
+	}
+}
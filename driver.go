@@ -5,13 +5,14 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
-	"fmt"
 	"io"
 	"reflect"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"go.opencensus.io/trace"
+	"go.opencensus.io/tag"
 )
 
 type conn interface {
@@ -26,9 +27,7 @@ type conn interface {
 }
 
 var (
-	regMu              sync.Mutex
-	attrMissingContext = trace.StringAttribute("ocsql.warning", "missing upstream context")
-	attrDeprecated     = trace.StringAttribute("ocsql.warning", "database driver uses deprecated features")
+	regMu sync.Mutex
 
 	// Compile time assertions
 	_ driver.Driver                         = &ocDriver{}
@@ -99,323 +98,277 @@ func RegisterWithSource(driverName string, source string, options ...TraceOption
 
 // Wrap takes a SQL driver and wraps it with OpenCensus instrumentation.
 func Wrap(d driver.Driver, options ...TraceOption) driver.Driver {
-	o := TraceOptions{}
-	for _, option := range options {
-		option(&o)
-	}
-	if o.InstanceName == "" {
-		o.InstanceName = defaultInstanceName
-	} else {
-		o.DefaultAttributes = append(o.DefaultAttributes, trace.StringAttribute("sql.instance", o.InstanceName))
-	}
-	if o.QueryParams && !o.Query {
-		o.QueryParams = false
-	}
-	return wrapDriver(d, o)
+	return wrapDriver(d, newTraceOptions(d, options...))
 }
 
 // Open implements driver.Driver
 func (d ocDriver) Open(name string) (driver.Conn, error) {
-	c, err := d.parent.Open(name)
+	options := dsnOptions(name, d.options)
+	c, err := connect(context.Background(), options, func(context.Context) (driver.Conn, error) {
+		return d.parent.Open(name)
+	})
 	if err != nil {
 		return nil, err
 	}
-	return wrapConn(c, d.options), nil
+	return wrapConn(c, options), nil
+}
+
+// connect establishes a connection using dial while recording the dial
+// latency and, if enabled, the sql:connect span.
+func connect(ctx context.Context, options TraceOptions, dial func(context.Context) (driver.Conn, error)) (c driver.Conn, err error) {
+	startTime := time.Now()
+	ctx, span := startConnSpan(ctx, "sql:connect", options)
+	defer func() {
+		recordConnectStats(ctx, startTime, options, err)
+		if span != nil {
+			setSpanStatus(span, options, err)
+			span.End()
+		}
+	}()
+	return dial(ctx)
 }
 
 // WrapConn allows an existing driver.Conn to be wrapped by ocsql.
 func WrapConn(c driver.Conn, options ...TraceOption) driver.Conn {
-	o := TraceOptions{}
-	for _, option := range options {
-		option(&o)
-	}
-	if o.InstanceName == "" {
-		o.InstanceName = defaultInstanceName
-	} else {
-		o.DefaultAttributes = append(o.DefaultAttributes, trace.StringAttribute("sql.instance", o.InstanceName))
-	}
-	return wrapConn(c, o)
+	return wrapConn(c, newTraceOptions(c, options...))
 }
 
 // ocConn implements driver.Conn
 type ocConn struct {
 	parent  driver.Conn
 	options TraceOptions
+	tx      *txState
+	usage   *connUsage
 }
 
-func (c ocConn) Ping(ctx context.Context) (err error) {
-	onDeferWithErr := recordCallStats(ctx, "go.sql.ping", c.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	if c.options.Ping && (c.options.AllowRoot || trace.FromContext(ctx) != nil) {
-		var span *trace.Span
-		ctx, span = trace.StartSpan(ctx, "sql:ping",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(c.options.Sampler),
-		)
-		if len(c.options.DefaultAttributes) > 0 {
-			span.AddAttributes(c.options.DefaultAttributes...)
-		}
-		defer func() {
-			if err != nil {
-				span.SetStatus(trace.Status{
-					Code:    trace.StatusCodeUnavailable,
-					Message: err.Error(),
-				})
-			} else {
-				span.SetStatus(trace.Status{Code: trace.StatusCodeOK})
-			}
-			span.End()
-		}()
-	}
-
-	if pinger, ok := c.parent.(driver.Pinger); ok {
-		err = pinger.Ping(ctx)
-	}
-	return
+func newConn(parent driver.Conn, options TraceOptions) *ocConn {
+	return &ocConn{parent: parent, options: options, usage: &connUsage{created: time.Now()}}
 }
 
-func (c ocConn) Exec(query string, args []driver.Value) (res driver.Result, err error) {
-	onDeferWithErr := recordCallStats(context.Background(), "go.sql.exec", c.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	if exec, ok := c.parent.(driver.Execer); ok {
-		if !c.options.AllowRoot {
-			return exec.Exec(query, args)
-		}
-
-		ctx, span := trace.StartSpan(context.Background(), "sql:exec",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(c.options.Sampler),
-		)
-		attrs := make([]trace.Attribute, 0, len(c.options.DefaultAttributes)+2)
-		attrs = append(attrs, c.options.DefaultAttributes...)
-		attrs = append(
-			attrs,
-			attrDeprecated,
-			trace.StringAttribute(
-				"ocsql.deprecated", "driver does not support ExecerContext",
-			),
-		)
-		if c.options.Query {
-			attrs = append(attrs, trace.StringAttribute("sql.query", query))
-			if c.options.QueryParams {
-				attrs = append(attrs, paramsAttr(args)...)
-			}
-		}
-		span.AddAttributes(attrs...)
-
-		defer func() {
-			setSpanStatus(span, c.options, err)
-			span.End()
-		}()
+// connUsage tracks the usage of a connection over its lifetime.
+type connUsage struct {
+	// accessed atomically and kept first for 64-bit alignment
+	statements int64
+	busy       int64
 
-		if res, err = exec.Exec(query, args); err != nil {
-			return nil, err
-		}
+	created time.Time
+}
 
-		return ocResult{parent: res, ctx: ctx, options: c.options}, nil
+// track adds the time since start, at which a call on the connection began,
+// to the time the connection spent busy. If statement is true, the call is
+// counted as a statement executed on the connection.
+func (u *connUsage) track(start time.Time, statement bool) {
+	if statement {
+		atomic.AddInt64(&u.statements, 1)
 	}
-
-	return nil, driver.ErrSkip
+	atomic.AddInt64(&u.busy, int64(time.Since(start)))
 }
 
-func (c ocConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
-	onDeferWithErr := recordCallStats(ctx, "go.sql.exec", c.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
+// begin starts the busy interval of a statement executed on the connection.
+func (u *connUsage) begin() *busyInterval {
+	atomic.AddInt64(&u.statements, 1)
+	return &busyInterval{usage: u, start: time.Now()}
+}
 
-	if execCtx, ok := c.parent.(driver.ExecerContext); ok {
-		parentSpan := trace.FromContext(ctx)
-		if !c.options.AllowRoot && parentSpan == nil {
-			return execCtx.ExecContext(ctx, query, args)
-		}
+// busyInterval is the time a connection spends serving a statement. The
+// interval of a query is taken over by its result set, keeping the connection
+// busy until the rows are finished.
+type busyInterval struct {
+	usage *connUsage
+	start time.Time
+	taken bool
+}
 
-		var span *trace.Span
-		if parentSpan == nil {
-			ctx, span = trace.StartSpan(ctx, "sql:exec",
-				trace.WithSpanKind(trace.SpanKindClient),
-				trace.WithSampler(c.options.Sampler),
-			)
-		} else {
-			_, span = trace.StartSpan(ctx, "sql:exec",
-				trace.WithSpanKind(trace.SpanKindClient),
-				trace.WithSampler(c.options.Sampler),
-			)
-		}
-		attrs := append([]trace.Attribute(nil), c.options.DefaultAttributes...)
-		if c.options.Query {
-			attrs = append(attrs, trace.StringAttribute("sql.query", query))
-			if c.options.QueryParams {
-				attrs = append(attrs, namedParamsAttr(args)...)
-			}
-		}
-		span.AddAttributes(attrs...)
+// end adds the interval to the time the connection spent busy, unless it was
+// taken over by a result set.
+func (b *busyInterval) end() {
+	if !b.taken {
+		b.usage.track(b.start, false)
+	}
+}
 
-		defer func() {
-			setSpanStatus(span, c.options, err)
-			span.End()
-		}()
+// summary returns the lifetime of the connection, the number of statements
+// it executed and the time it spent busy.
+func (u *connUsage) summary() (lifetime time.Duration, statements int64, busy time.Duration) {
+	return time.Since(u.created), atomic.LoadInt64(&u.statements), time.Duration(atomic.LoadInt64(&u.busy))
+}
 
-		if res, err = execCtx.ExecContext(ctx, query, args); err != nil {
-			return nil, err
-		}
+// txState tracks the transaction span of the transaction currently open on a
+// connection.
+type txState struct {
+	tracer     Tracer
+	span       Span
+	statements int64
+}
 
-		return ocResult{parent: res, ctx: ctx, options: c.options}, nil
+// txContext returns ctx with the span of the transaction currently open on
+// the connection as parent span, so statements are grouped per transaction.
+// The span active in ctx, typically the one of the caller, is replaced: the
+// statements become children of the transaction span, which itself is a
+// child of the span active when the transaction began.
+func (c *ocConn) txContext(ctx context.Context) context.Context {
+	if c.tx == nil {
+		return ctx
 	}
+	return c.tx.tracer.NewContext(ctx, c.tx.span)
+}
 
-	return nil, driver.ErrSkip
+// txStatement is like txContext but also counts the statement as executed
+// within the transaction currently open on the connection.
+func (c *ocConn) txStatement(ctx context.Context) context.Context {
+	if c.tx == nil {
+		return ctx
+	}
+	c.tx.statements++
+	return c.tx.tracer.NewContext(ctx, c.tx.span)
 }
 
-func (c ocConn) Query(query string, args []driver.Value) (rows driver.Rows, err error) {
-	onDeferWithErr := recordCallStats(context.Background(), "go.sql.query", c.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
+// skipCall records the stats of a call the parent driver does not implement
+// and returns driver.ErrSkip, upon which database/sql retries the call with a
+// prepared statement. The skipped call is counted as failed call with the
+// err_skip error class, no span is created for it.
+func (c *ocConn) skipCall(ctx context.Context, method string) error {
+	recordCallStats(ctx, method, "", contextOptions(ctx, c.options))(driver.ErrSkip)
+	return driver.ErrSkip
+}
 
-	if queryer, ok := c.parent.(driver.Queryer); ok {
-		if !c.options.AllowRoot {
-			return queryer.Query(query, args)
+func (c *ocConn) Ping(ctx context.Context) error {
+	recordAcquire(ctx, c)
+	defer c.usage.track(time.Now(), false)
+	options := contextOptions(ctx, c.options)
+	return options.interceptors.ping(func(ctx context.Context, _ *Call) error {
+		if pinger, ok := c.parent.(driver.Pinger); ok {
+			return pinger.Ping(ctx)
 		}
+		return nil
+	})(ctx, &Call{Method: "go.sql.ping"})
+}
 
-		ctx, span := trace.StartSpan(context.Background(), "sql:query",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(c.options.Sampler),
-		)
-		attrs := make([]trace.Attribute, 0, len(c.options.DefaultAttributes)+2)
-		attrs = append(attrs, c.options.DefaultAttributes...)
-		attrs = append(
-			attrs,
-			attrDeprecated,
-			trace.StringAttribute(
-				"ocsql.deprecated", "driver does not support QueryerContext",
-			),
-		)
-		if c.options.Query {
-			attrs = append(attrs, trace.StringAttribute("sql.query", query))
-			if c.options.QueryParams {
-				attrs = append(attrs, paramsAttr(args)...)
-			}
-		}
-		span.AddAttributes(attrs...)
-
-		defer func() {
-			setSpanStatus(span, c.options, err)
-			span.End()
-		}()
+func (c *ocConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	exec, ok := c.parent.(driver.Execer)
+	if !ok {
+		return nil, c.skipCall(context.Background(), "go.sql.exec")
+	}
+	defer c.usage.track(time.Now(), true)
 
-		rows, err = queryer.Query(query, args)
+	ctx := c.txStatement(context.Background())
+	call := newCall("go.sql.exec", query, namedValues(args), c.options)
+	call.deprecated = "driver does not support ExecerContext"
+	return c.options.interceptors.exec(func(ctx context.Context, call *Call) (driver.Result, error) {
+		res, err := exec.Exec(call.Query, values(call.Args))
 		if err != nil {
 			return nil, err
 		}
+		return wrapResult(ctx, res, "go.sql.exec", query, c.options), nil
+	})(ctx, call)
+}
 
-		return wrapRows(ctx, rows, c.options), nil
+func (c *ocConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	recordAcquire(ctx, c)
+	execCtx, ok := c.parent.(driver.ExecerContext)
+	if !ok {
+		return nil, c.skipCall(ctx, "go.sql.exec")
 	}
+	defer c.usage.track(time.Now(), true)
 
-	return nil, driver.ErrSkip
+	ctx = c.txStatement(ctx)
+	options := contextOptions(ctx, c.options)
+	call := newCall("go.sql.exec", query, args, options)
+	return options.interceptors.exec(func(ctx context.Context, call *Call) (driver.Result, error) {
+		res, err := execCtx.ExecContext(ctx, call.Query, call.Args)
+		if err != nil {
+			return nil, err
+		}
+		return wrapResult(ctx, res, "go.sql.exec", query, options), nil
+	})(ctx, call)
 }
 
-func (c ocConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
-	onDeferWithErr := recordCallStats(ctx, "go.sql.query", c.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	if queryerCtx, ok := c.parent.(driver.QueryerContext); ok {
-		parentSpan := trace.FromContext(ctx)
-		if !c.options.AllowRoot && parentSpan == nil {
-			return queryerCtx.QueryContext(ctx, query, args)
-		}
+func (c *ocConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	queryer, ok := c.parent.(driver.Queryer)
+	if !ok {
+		return nil, c.skipCall(context.Background(), "go.sql.query")
+	}
+	busy := c.usage.begin()
+	defer busy.end()
 
-		var span *trace.Span
-		if parentSpan == nil {
-			ctx, span = trace.StartSpan(ctx, "sql:query",
-				trace.WithSpanKind(trace.SpanKindClient),
-				trace.WithSampler(c.options.Sampler),
-			)
-		} else {
-			_, span = trace.StartSpan(ctx, "sql:query",
-				trace.WithSpanKind(trace.SpanKindClient),
-				trace.WithSampler(c.options.Sampler),
-			)
-		}
-		attrs := append([]trace.Attribute(nil), c.options.DefaultAttributes...)
-		if c.options.Query {
-			attrs = append(attrs, trace.StringAttribute("sql.query", query))
-			if c.options.QueryParams {
-				attrs = append(attrs, namedParamsAttr(args)...)
-			}
+	ctx := c.txStatement(context.Background())
+	call := newCall("go.sql.query", query, namedValues(args), c.options)
+	call.deprecated = "driver does not support QueryerContext"
+	return c.options.interceptors.query(func(ctx context.Context, call *Call) (driver.Rows, error) {
+		rows, err := queryer.Query(call.Query, values(call.Args))
+		if err != nil {
+			return nil, err
 		}
-		span.AddAttributes(attrs...)
+		return wrapRows(ctx, rows, "go.sql.query", query, c.options, busy), nil
+	})(ctx, call)
+}
 
-		defer func() {
-			setSpanStatus(span, c.options, err)
-			span.End()
-		}()
+func (c *ocConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	recordAcquire(ctx, c)
+	queryerCtx, ok := c.parent.(driver.QueryerContext)
+	if !ok {
+		return nil, c.skipCall(ctx, "go.sql.query")
+	}
+	busy := c.usage.begin()
+	defer busy.end()
 
-		rows, err = queryerCtx.QueryContext(ctx, query, args)
+	ctx = c.txStatement(ctx)
+	options := contextOptions(ctx, c.options)
+	call := newCall("go.sql.query", query, args, options)
+	return options.interceptors.query(func(ctx context.Context, call *Call) (driver.Rows, error) {
+		rows, err := queryerCtx.QueryContext(ctx, call.Query, call.Args)
 		if err != nil {
 			return nil, err
 		}
+		return wrapRows(ctx, rows, "go.sql.query", query, options, busy), nil
+	})(ctx, call)
+}
 
-		return wrapRows(ctx, rows, c.options), nil
+func (c *ocConn) Prepare(query string) (driver.Stmt, error) {
+	defer c.usage.track(time.Now(), false)
+	ctx := c.txContext(context.Background())
+	call := newCall("go.sql.prepare", query, nil, c.options)
+	call.missingContext = true
+	stmt, err := c.options.interceptors.prepare(func(_ context.Context, call *Call) (driver.Stmt, error) {
+		return c.parent.Prepare(call.Query)
+	})(ctx, call)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, driver.ErrSkip
+	return wrapStmt(stmt, query, c), nil
 }
 
-func (c ocConn) Prepare(query string) (stmt driver.Stmt, err error) {
-	onDeferWithErr := recordCallStats(context.Background(), "go.sql.prepare", c.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
+func (c *ocConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	recordAcquire(ctx, c)
+	defer c.usage.track(time.Now(), false)
+	prepCtx, hasPrepareContext := c.parent.(driver.ConnPrepareContext)
 
-	if c.options.AllowRoot {
-		_, span := trace.StartSpan(context.Background(), "sql:prepare",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(c.options.Sampler),
-		)
-		attrs := make([]trace.Attribute, 0, len(c.options.DefaultAttributes)+1)
-		attrs = append(attrs, c.options.DefaultAttributes...)
-		attrs = append(attrs, attrMissingContext)
-		if c.options.Query {
-			attrs = append(attrs, trace.StringAttribute("sql.query", query))
+	ctx = c.txContext(ctx)
+	options := contextOptions(ctx, c.options)
+	call := newCall("go.sql.prepare", query, nil, options)
+	call.missingContext = !hasPrepareContext
+	stmt, err := options.interceptors.prepare(func(ctx context.Context, call *Call) (driver.Stmt, error) {
+		if hasPrepareContext {
+			return prepCtx.PrepareContext(ctx, call.Query)
 		}
-		span.AddAttributes(attrs...)
+		return c.parent.Prepare(call.Query)
+	})(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	return wrapStmt(stmt, query, c), nil
+}
 
+func (c *ocConn) Close() (err error) {
+	lifetime, statements, busy := c.usage.summary()
+	recordConnStats(lifetime, statements, busy, c.options)
+	if _, span := startConnSpan(context.Background(), "sql:conn_close", c.options); span != nil {
+		span.AddAttributes(connUsageAttrs(lifetime, statements, busy)...)
 		defer func() {
 			setSpanStatus(span, c.options, err)
 			span.End()
 		}()
 	}
-
-	stmt, err = c.parent.Prepare(query)
-	if err != nil {
-		return nil, err
-	}
-
-	stmt = wrapStmt(stmt, query, c.options)
-	return
-}
-
-func (c *ocConn) Close() error {
 	return c.parent.Close()
 }
 
@@ -423,107 +376,41 @@ func (c *ocConn) Begin() (driver.Tx, error) {
 	return c.BeginTx(context.TODO(), driver.TxOptions{})
 }
 
-func (c *ocConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
-	onDeferWithErr := recordCallStats(ctx, "go.sql.prepare", c.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
+func (c *ocConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	recordAcquire(ctx, c)
+	start := time.Now()
+	defer c.usage.track(start, false)
+	options := contextOptions(ctx, c.options)
+	connBeginTx, hasBeginTx := c.parent.(driver.ConnBeginTx)
 
-	var span *trace.Span
-	attrs := append([]trace.Attribute(nil), c.options.DefaultAttributes...)
-	if c.options.AllowRoot || trace.FromContext(ctx) != nil {
-		ctx, span = trace.StartSpan(ctx, "sql:prepare",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(c.options.Sampler),
-		)
-		if c.options.Query {
-			attrs = append(attrs, trace.StringAttribute("sql.query", query))
-		}
-		defer func() {
-			setSpanStatus(span, c.options, err)
-			span.End()
-		}()
+	call := &Call{Method: "go.sql.begin", TxOptions: opts}
+	if !hasBeginTx {
+		call.deprecated = "driver does not support ConnBeginTx"
 	}
-
-	if prepCtx, ok := c.parent.(driver.ConnPrepareContext); ok {
-		stmt, err = prepCtx.PrepareContext(ctx, query)
-	} else {
-		if span != nil {
-			attrs = append(attrs, attrMissingContext)
+	tx, err := options.interceptors.tx(func(ctx context.Context, call *Call) (driver.Tx, error) {
+		if hasBeginTx {
+			return connBeginTx.BeginTx(ctx, call.TxOptions)
 		}
-		stmt, err = c.parent.Prepare(query)
-	}
-	span.AddAttributes(attrs...)
+		return c.parent.Begin()
+	})(ctx, call)
 	if err != nil {
 		return nil, err
 	}
 
-	stmt = wrapStmt(stmt, query, c.options)
-	return
-}
-
-func (c *ocConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
-	onDeferWithErr := recordCallStats(ctx, "go.sql.begin", c.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	if !c.options.AllowRoot && trace.FromContext(ctx) == nil {
-		if connBeginTx, ok := c.parent.(driver.ConnBeginTx); ok {
-			return connBeginTx.BeginTx(ctx, opts)
-		}
-		return c.parent.Begin()
-	}
-
-	var span *trace.Span
-	attrs := append([]trace.Attribute(nil), c.options.DefaultAttributes...)
-
-	if ctx == nil || ctx == context.TODO() {
-		ctx = context.Background()
-		_, span = trace.StartSpan(ctx, "sql:begin_transaction",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(c.options.Sampler),
-		)
-		attrs = append(attrs, attrMissingContext)
-	} else {
-		_, span = trace.StartSpan(ctx, "sql:begin_transaction",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(c.options.Sampler),
-		)
-	}
-	defer func() {
-		if len(attrs) > 0 {
-			span.AddAttributes(attrs...)
-		}
-		span.End()
-	}()
-
-	if connBeginTx, ok := c.parent.(driver.ConnBeginTx); ok {
-		tx, err = connBeginTx.BeginTx(ctx, opts)
-		setSpanStatus(span, c.options, err)
-		if err != nil {
-			return nil, err
-		}
-		return ocTx{parent: tx, ctx: ctx, options: c.options}, nil
+	if call.tx != nil {
+		ctx = call.tx.tracer.NewContext(ctx, call.tx.span)
 	}
+	c.tx = call.tx
+	return c.wrapTx(ctx, tx, call.tx, start, options), nil
+}
 
-	attrs = append(
-		attrs,
-		attrDeprecated,
-		trace.StringAttribute(
-			"ocsql.deprecated", "driver does not support ConnBeginTx",
-		),
-	)
-	tx, err = c.parent.Begin()
-	setSpanStatus(span, c.options, err)
-	if err != nil {
-		return nil, err
+// wrapTx returns an ocTx for the transaction begun at start.
+func (c *ocConn) wrapTx(ctx context.Context, parent driver.Tx, state *txState, start time.Time, options TraceOptions) ocTx {
+	t := ocTx{parent: parent, ctx: ctx, options: options, conn: c, state: state, start: start}
+	if options.LeakDetector != nil {
+		t.leak = options.LeakDetector.track(ctx, HandleKindTx, "go.sql.begin", "", options)
 	}
-	return ocTx{parent: tx, ctx: ctx, options: c.options}, nil
+	return t
 }
 
 func (c *ocConn) CheckNamedValue(nv *driver.NamedValue) (err error) {
@@ -539,99 +426,79 @@ func (c *ocConn) CheckNamedValue(nv *driver.NamedValue) (err error) {
 type ocResult struct {
 	parent  driver.Result
 	ctx     context.Context
+	query   string
 	options TraceOptions
+
+	// rowsAffected holds the result of an eager RowsAffected call.
+	hasRowsAffected bool
+	rowsAffected    int64
+	rowsAffectedErr error
 }
 
-func (r ocResult) LastInsertId() (id int64, err error) {
-	if r.options.LastInsertID {
-		_, span := trace.StartSpan(r.ctx, "sql:last_insert_id",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(r.options.Sampler),
-		)
-		if len(r.options.DefaultAttributes) > 0 {
-			span.AddAttributes(r.options.DefaultAttributes...)
-		}
-		defer func() {
-			setSpanStatus(span, r.options, err)
-			span.End()
-		}()
+// wrapResult wraps the driver.Result of an exec call. If the EagerRowsAffected
+// option is enabled, the number of affected rows is retrieved right away and
+// recorded as stats; the tracing interceptor adds it to the exec span. This
+// forces a RowsAffected call on the parent result of every exec; its outcome
+// is cached for the RowsAffected calls of the application.
+func wrapResult(ctx context.Context, parent driver.Result, method, query string, options TraceOptions) ocResult {
+	r := ocResult{parent: parent, ctx: ctx, query: query, options: options}
+	if !options.EagerRowsAffected {
+		return r
 	}
 
-	id, err = r.parent.LastInsertId()
-	return
+	r.hasRowsAffected = true
+	r.rowsAffected, r.rowsAffectedErr = parent.RowsAffected()
+	if r.rowsAffectedErr != nil {
+		return r
+	}
+	recorderFor(options).Record(ctx, append([]tag.Mutator{
+		tag.Insert(GoSQLMethod, method), tag.Insert(GoSQLInstance, options.InstanceName),
+	}, contextTags(ctx, options)...), MeasureRowsAffected.M(r.rowsAffected))
+	return r
 }
 
-func (r ocResult) RowsAffected() (cnt int64, err error) {
-	if r.options.RowsAffected {
-		_, span := trace.StartSpan(r.ctx, "sql:rows_affected",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(r.options.Sampler),
-		)
-		if len(r.options.DefaultAttributes) > 0 {
-			span.AddAttributes(r.options.DefaultAttributes...)
-		}
-		defer func() {
-			setSpanStatus(span, r.options, err)
-			span.End()
-		}()
-	}
+func (r ocResult) LastInsertId() (int64, error) {
+	return r.options.interceptors.result(func(context.Context, *Call) (int64, error) {
+		return r.parent.LastInsertId()
+	})(r.ctx, &Call{Method: "go.sql.last_insert_id", Query: r.query})
+}
 
-	cnt, err = r.parent.RowsAffected()
-	return
+func (r ocResult) RowsAffected() (int64, error) {
+	return r.options.interceptors.result(func(context.Context, *Call) (int64, error) {
+		if r.hasRowsAffected {
+			return r.rowsAffected, r.rowsAffectedErr
+		}
+		return r.parent.RowsAffected()
+	})(r.ctx, &Call{Method: "go.sql.rows_affected", Query: r.query})
 }
 
 // ocStmt implements driver.Stmt
 type ocStmt struct {
-	parent  driver.Stmt
-	query   string
-	options TraceOptions
-}
-
-func (s ocStmt) Exec(args []driver.Value) (res driver.Result, err error) {
-	onDeferWithErr := recordCallStats(context.Background(), "go.sql.stmt.exec", s.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	if !s.options.AllowRoot {
-		return s.parent.Exec(args)
-	}
-
-	ctx, span := trace.StartSpan(context.Background(), "sql:exec",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithSampler(s.options.Sampler),
-	)
-	attrs := make([]trace.Attribute, 0, len(s.options.DefaultAttributes)+2)
-	attrs = append(attrs, s.options.DefaultAttributes...)
-	attrs = append(
-		attrs,
-		attrDeprecated,
-		trace.StringAttribute(
-			"ocsql.deprecated", "driver does not support StmtExecContext",
-		),
-	)
-	if s.options.Query {
-		attrs = append(attrs, trace.StringAttribute("sql.query", s.query))
-		if s.options.QueryParams {
-			attrs = append(attrs, paramsAttr(args)...)
+	parent    driver.Stmt
+	query     string
+	operation string
+	table     string
+	options   TraceOptions
+	conn      *ocConn
+}
+
+// newCall returns the Call of a statement method.
+func (s ocStmt) newCall(method string, args []driver.NamedValue) *Call {
+	return &Call{Method: method, Query: s.query, Args: args, Operation: s.operation, Table: s.table}
+}
+
+func (s ocStmt) Exec(args []driver.Value) (driver.Result, error) {
+	defer s.conn.usage.track(time.Now(), true)
+	ctx := s.conn.txStatement(context.Background())
+	call := s.newCall("go.sql.stmt.exec", namedValues(args))
+	call.deprecated = "driver does not support StmtExecContext"
+	return s.options.interceptors.exec(func(ctx context.Context, call *Call) (driver.Result, error) {
+		res, err := s.parent.Exec(values(call.Args))
+		if err != nil {
+			return nil, err
 		}
-	}
-	span.AddAttributes(attrs...)
-
-	defer func() {
-		setSpanStatus(span, s.options, err)
-		span.End()
-	}()
-
-	res, err = s.parent.Exec(args)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err = ocResult{parent: res, ctx: ctx, options: s.options}, nil
-	return
+		return wrapResult(ctx, res, "go.sql.stmt.exec", s.query, s.options), nil
+	})(ctx, call)
 }
 
 func (s ocStmt) Close() error {
@@ -642,150 +509,50 @@ func (s ocStmt) NumInput() int {
 	return s.parent.NumInput()
 }
 
-func (s ocStmt) Query(args []driver.Value) (rows driver.Rows, err error) {
-	onDeferWithErr := recordCallStats(context.Background(), "go.sql.stmt.query", s.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	if !s.options.AllowRoot {
-		return s.parent.Query(args)
-	}
-
-	ctx, span := trace.StartSpan(context.Background(), "sql:query",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithSampler(s.options.Sampler),
-	)
-	attrs := make([]trace.Attribute, 0, len(s.options.DefaultAttributes)+2)
-	attrs = append(attrs, s.options.DefaultAttributes...)
-	attrs = append(
-		attrs,
-		attrDeprecated,
-		trace.StringAttribute(
-			"ocsql.deprecated", "driver does not support StmtQueryContext",
-		),
-	)
-	if s.options.Query {
-		attrs = append(attrs, trace.StringAttribute("sql.query", s.query))
-		if s.options.QueryParams {
-			attrs = append(attrs, paramsAttr(args)...)
+func (s ocStmt) Query(args []driver.Value) (driver.Rows, error) {
+	busy := s.conn.usage.begin()
+	defer busy.end()
+	ctx := s.conn.txStatement(context.Background())
+	call := s.newCall("go.sql.stmt.query", namedValues(args))
+	call.deprecated = "driver does not support StmtQueryContext"
+	return s.options.interceptors.query(func(ctx context.Context, call *Call) (driver.Rows, error) {
+		rows, err := s.parent.Query(values(call.Args))
+		if err != nil {
+			return nil, err
 		}
-	}
-	span.AddAttributes(attrs...)
-
-	defer func() {
-		setSpanStatus(span, s.options, err)
-		span.End()
-	}()
-
-	rows, err = s.parent.Query(args)
-	if err != nil {
-		return nil, err
-	}
-	rows, err = wrapRows(ctx, rows, s.options), nil
-	return
+		return wrapRows(ctx, rows, "go.sql.stmt.query", s.query, s.options, busy), nil
+	})(ctx, call)
 }
 
-func (s ocStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
-	onDeferWithErr := recordCallStats(ctx, "go.sql.stmt.exec", s.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	parentSpan := trace.FromContext(ctx)
-	if !s.options.AllowRoot && parentSpan == nil {
+func (s ocStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	recordAcquire(ctx, s.conn)
+	defer s.conn.usage.track(time.Now(), true)
+	ctx = s.conn.txStatement(ctx)
+	options := contextOptions(ctx, s.options)
+	return options.interceptors.exec(func(ctx context.Context, call *Call) (driver.Result, error) {
 		// we already tested driver to implement StmtExecContext
-		return s.parent.(driver.StmtExecContext).ExecContext(ctx, args)
-	}
-
-	var span *trace.Span
-	if parentSpan == nil {
-		ctx, span = trace.StartSpan(ctx, "sql:exec",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(s.options.Sampler),
-		)
-	} else {
-		_, span = trace.StartSpan(ctx, "sql:exec",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(s.options.Sampler),
-		)
-	}
-	attrs := append([]trace.Attribute(nil), s.options.DefaultAttributes...)
-	if s.options.Query {
-		attrs = append(attrs, trace.StringAttribute("sql.query", s.query))
-		if s.options.QueryParams {
-			attrs = append(attrs, namedParamsAttr(args)...)
+		res, err := s.parent.(driver.StmtExecContext).ExecContext(ctx, call.Args)
+		if err != nil {
+			return nil, err
 		}
-	}
-	span.AddAttributes(attrs...)
-
-	defer func() {
-		setSpanStatus(span, s.options, err)
-		span.End()
-	}()
-
-	// we already tested driver to implement StmtExecContext
-	execContext := s.parent.(driver.StmtExecContext)
-	res, err = execContext.ExecContext(ctx, args)
-	if err != nil {
-		return nil, err
-	}
-	res, err = ocResult{parent: res, ctx: ctx, options: s.options}, nil
-	return
+		return wrapResult(ctx, res, "go.sql.stmt.exec", s.query, options), nil
+	})(ctx, s.newCall("go.sql.stmt.exec", args))
 }
 
-func (s ocStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
-	onDeferWithErr := recordCallStats(ctx, "go.sql.stmt.query", s.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	parentSpan := trace.FromContext(ctx)
-	if !s.options.AllowRoot && parentSpan == nil {
+func (s ocStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	recordAcquire(ctx, s.conn)
+	busy := s.conn.usage.begin()
+	defer busy.end()
+	ctx = s.conn.txStatement(ctx)
+	options := contextOptions(ctx, s.options)
+	return options.interceptors.query(func(ctx context.Context, call *Call) (driver.Rows, error) {
 		// we already tested driver to implement StmtQueryContext
-		return s.parent.(driver.StmtQueryContext).QueryContext(ctx, args)
-	}
-
-	var span *trace.Span
-	if parentSpan == nil {
-		ctx, span = trace.StartSpan(ctx, "sql:query",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(s.options.Sampler),
-		)
-	} else {
-		_, span = trace.StartSpan(ctx, "sql:query",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(s.options.Sampler),
-		)
-	}
-	attrs := append([]trace.Attribute(nil), s.options.DefaultAttributes...)
-	if s.options.Query {
-		attrs = append(attrs, trace.StringAttribute("sql.query", s.query))
-		if s.options.QueryParams {
-			attrs = append(attrs, namedParamsAttr(args)...)
+		rows, err := s.parent.(driver.StmtQueryContext).QueryContext(ctx, call.Args)
+		if err != nil {
+			return nil, err
 		}
-	}
-	span.AddAttributes(attrs...)
-
-	defer func() {
-		setSpanStatus(span, s.options, err)
-		span.End()
-	}()
-
-	// we already tested driver to implement StmtQueryContext
-	queryContext := s.parent.(driver.StmtQueryContext)
-	rows, err = queryContext.QueryContext(ctx, args)
-	if err != nil {
-		return nil, err
-	}
-	rows, err = wrapRows(ctx, rows, s.options), nil
-	return
+		return wrapRows(ctx, rows, "go.sql.stmt.query", s.query, options, busy), nil
+	})(ctx, s.newCall("go.sql.stmt.query", args))
 }
 
 // withRowsColumnTypeScanType is the same as the driver.RowsColumnTypeScanType
@@ -802,13 +569,28 @@ type withRowsColumnTypeScanType interface {
 type ocRows struct {
 	parent  driver.Rows
 	ctx     context.Context
+	method  string
+	query   string
 	options TraceOptions
+
+	// intercept invokes the interceptor chain of Next and Close calls.
+	intercept RowsFunc
+	nextCall  *Call
+	closeCall *Call
+
+	span     Span
+	start    time.Time
+	firstRow time.Duration
+	count    int64
+	finished bool
+	busy     *busyInterval
+	leak     *leakHandle
 }
 
 // HasNextResultSet calls the implements the driver.RowsNextResultSet for ocRows.
 // It returns the the underlying result of HasNextResultSet from the ocRows.parent
 // if the parent implements driver.RowsNextResultSet.
-func (r ocRows) HasNextResultSet() bool {
+func (r *ocRows) HasNextResultSet() bool {
 	if v, ok := r.parent.(driver.RowsNextResultSet); ok {
 		return v.HasNextResultSet()
 	}
@@ -819,7 +601,7 @@ func (r ocRows) HasNextResultSet() bool {
 // NextResultsSet calls the implements the driver.RowsNextResultSet for ocRows.
 // It returns the the underlying result of NextResultSet from the ocRows.parent
 // if the parent implements driver.RowsNextResultSet.
-func (r ocRows) NextResultSet() error {
+func (r *ocRows) NextResultSet() error {
 	if v, ok := r.parent.(driver.RowsNextResultSet); ok {
 		return v.NextResultSet()
 	}
@@ -830,7 +612,7 @@ func (r ocRows) NextResultSet() error {
 // ColumnTypeDatabaseTypeName calls the implements the driver.RowsColumnTypeDatabaseTypeName for ocRows.
 // It returns the the underlying result of ColumnTypeDatabaseTypeName from the ocRows.parent
 // if the parent implements driver.RowsColumnTypeDatabaseTypeName.
-func (r ocRows) ColumnTypeDatabaseTypeName(index int) string {
+func (r *ocRows) ColumnTypeDatabaseTypeName(index int) string {
 	if v, ok := r.parent.(driver.RowsColumnTypeDatabaseTypeName); ok {
 		return v.ColumnTypeDatabaseTypeName(index)
 	}
@@ -841,7 +623,7 @@ func (r ocRows) ColumnTypeDatabaseTypeName(index int) string {
 // ColumnTypeLength calls the implements the driver.RowsColumnTypeLength for ocRows.
 // It returns the the underlying result of ColumnTypeLength from the ocRows.parent
 // if the parent implements driver.RowsColumnTypeLength.
-func (r ocRows) ColumnTypeLength(index int) (length int64, ok bool) {
+func (r *ocRows) ColumnTypeLength(index int) (length int64, ok bool) {
 	if v, ok := r.parent.(driver.RowsColumnTypeLength); ok {
 		return v.ColumnTypeLength(index)
 	}
@@ -852,7 +634,7 @@ func (r ocRows) ColumnTypeLength(index int) (length int64, ok bool) {
 // ColumnTypeNullable calls the implements the driver.RowsColumnTypeNullable for ocRows.
 // It returns the the underlying result of ColumnTypeNullable from the ocRows.parent
 // if the parent implements driver.RowsColumnTypeNullable.
-func (r ocRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+func (r *ocRows) ColumnTypeNullable(index int) (nullable, ok bool) {
 	if v, ok := r.parent.(driver.RowsColumnTypeNullable); ok {
 		return v.ColumnTypeNullable(index)
 	}
@@ -863,7 +645,7 @@ func (r ocRows) ColumnTypeNullable(index int) (nullable, ok bool) {
 // ColumnTypePrecisionScale calls the implements the driver.RowsColumnTypePrecisionScale for ocRows.
 // It returns the the underlying result of ColumnTypePrecisionScale from the ocRows.parent
 // if the parent implements driver.RowsColumnTypePrecisionScale.
-func (r ocRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+func (r *ocRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
 	if v, ok := r.parent.(driver.RowsColumnTypePrecisionScale); ok {
 		return v.ColumnTypePrecisionScale(index)
 	}
@@ -871,51 +653,60 @@ func (r ocRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok
 	return 0, 0, false
 }
 
-func (r ocRows) Columns() []string {
+func (r *ocRows) Columns() []string {
 	return r.parent.Columns()
 }
 
-func (r ocRows) Close() (err error) {
-	if r.options.RowsClose {
-		_, span := trace.StartSpan(r.ctx, "sql:rows_close",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(r.options.Sampler),
-		)
-		if len(r.options.DefaultAttributes) > 0 {
-			span.AddAttributes(r.options.DefaultAttributes...)
+func (r *ocRows) Close() (err error) {
+	defer func() {
+		r.finish(err)
+		if r.leak != nil {
+			r.options.LeakDetector.untrack(r.leak)
 		}
-		defer func() {
-			setSpanStatus(span, r.options, err)
-			span.End()
-		}()
-	}
+	}()
 
-	err = r.parent.Close()
-	return
+	return r.intercept(r.ctx, r.closeCall, nil)
 }
 
-func (r ocRows) Next(dest []driver.Value) (err error) {
-	if r.options.RowsNext {
-		_, span := trace.StartSpan(r.ctx, "sql:rows_next",
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(r.options.Sampler),
-		)
-		if len(r.options.DefaultAttributes) > 0 {
-			span.AddAttributes(r.options.DefaultAttributes...)
-		}
-		defer func() {
-			if err == io.EOF {
-				// not an error; expected to happen during iteration
-				setSpanStatus(span, r.options, nil)
-			} else {
-				setSpanStatus(span, r.options, err)
+func (r *ocRows) Next(dest []driver.Value) (err error) {
+	defer func() {
+		switch err {
+		case nil:
+			if r.count == 0 {
+				r.firstRow = time.Since(r.start)
 			}
-			span.End()
-		}()
+			r.count++
+		case io.EOF:
+			// not an error; iteration has completed
+			r.finish(nil)
+		default:
+			r.finish(err)
+		}
+	}()
+
+	return r.intercept(r.ctx, r.nextCall, dest)
+}
+
+// finish records the number of rows returned, ends the busy interval of the
+// query and ends the sql:rows span if one was created. Only the first invocation has effect, which happens on
+// io.EOF, an iteration error or Close, whichever comes first.
+func (r *ocRows) finish(err error) {
+	if r.finished {
+		return
+	}
+	r.finished = true
+	if r.busy != nil {
+		r.busy.usage.track(r.busy.start, false)
 	}
 
-	err = r.parent.Next(dest)
-	return
+	fetchDuration := time.Since(r.start)
+	recorderFor(r.options).Record(r.ctx, []tag.Mutator{
+		tag.Insert(GoSQLMethod, r.method), tag.Insert(GoSQLInstance, r.options.InstanceName),
+	}, MeasureRowsReturned.M(r.count))
+
+	if r.span != nil {
+		endRowsSpan(r.span, r.count, r.firstRow, fetchDuration, r.options, err)
+	}
 }
 
 // wrapRows returns a struct which conforms to the driver.Rows interface.
@@ -924,20 +715,44 @@ func (r ocRows) Next(dest []driver.Value) (err error) {
 // Currently the one exception is RowsColumnTypeScanType which does not have a
 // valid zero value. This interface is tested for and only enabled in case the
 // parent implementation supports it.
-func wrapRows(ctx context.Context, parent driver.Rows, options TraceOptions) driver.Rows {
+func wrapRows(ctx context.Context, parent driver.Rows, method, query string, options TraceOptions, busy *busyInterval) driver.Rows {
 	var (
 		ts, hasColumnTypeScan = parent.(driver.RowsColumnTypeScanType)
 	)
 
-	r := ocRows{
+	r := &ocRows{
 		parent:  parent,
 		ctx:     ctx,
+		method:  method,
+		query:   query,
 		options: options,
+		start:   time.Now(),
+	}
+	if busy != nil {
+		// the connection stays busy until the rows are finished
+		busy.taken = true
+		r.busy = busy
+	}
+	r.intercept = options.interceptors.rows(func(_ context.Context, call *Call, dest []driver.Value) error {
+		if call.Method == "go.sql.rows.close" {
+			return parent.Close()
+		}
+		return parent.Next(dest)
+	})
+	r.nextCall = &Call{Method: "go.sql.rows.next", Query: query}
+	r.closeCall = &Call{Method: "go.sql.rows.close", Query: query}
+
+	if options.Rows && !options.SkipTracing {
+		r.ctx, r.span = startRowsSpan(ctx, query, options)
+	}
+
+	if options.LeakDetector != nil {
+		r.leak = options.LeakDetector.track(r.ctx, HandleKindRows, method, query, options)
 	}
 
 	if hasColumnTypeScan {
 		return struct {
-			ocRows
+			*ocRows
 			withRowsColumnTypeScanType
 		}{r, ts}
 	}
@@ -945,133 +760,135 @@ func wrapRows(ctx context.Context, parent driver.Rows, options TraceOptions) dri
 	return r
 }
 
+// The following values are recorded as outcome of transactions.
+const (
+	txOutcomeCommit   = "commit"
+	txOutcomeRollback = "rollback"
+	txOutcomeError    = "error"
+)
+
 // ocTx implements driver.Tx
 type ocTx struct {
 	parent  driver.Tx
 	ctx     context.Context
 	options TraceOptions
+	conn    *ocConn
+	state   *txState
+	start   time.Time
+	leak    *leakHandle
 }
 
-func (t ocTx) Commit() (err error) {
-	onDeferWithErr := recordCallStats(context.Background(), "go.sql.commit", t.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
+// end records the transaction stats and ends the transaction span, if any,
+// with the provided outcome.
+func (t ocTx) end(outcome string, err error) {
+	if err != nil {
+		outcome = txOutcomeError
+	}
+	recordTxStats(t.ctx, t.start, outcome, t.options)
+	if t.leak != nil {
+		t.options.LeakDetector.untrack(t.leak)
+	}
 
-	_, span := trace.StartSpan(t.ctx, "sql:commit",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithSampler(t.options.Sampler),
-	)
-	if len(t.options.DefaultAttributes) > 0 {
-		span.AddAttributes(t.options.DefaultAttributes...)
+	if t.state == nil {
+		return
+	}
+	if t.conn.tx == t.state {
+		t.conn.tx = nil
 	}
+	endTxSpan(t.state, outcome, t.options, err)
+}
+
+func (t ocTx) Commit() (err error) {
+	defer t.conn.usage.track(time.Now(), false)
 	defer func() {
-		setSpanStatus(span, t.options, err)
-		span.End()
+		t.end(txOutcomeCommit, err)
 	}()
 
-	err = t.parent.Commit()
+	_, err = t.options.interceptors.tx(func(context.Context, *Call) (driver.Tx, error) {
+		return nil, t.parent.Commit()
+	})(t.ctx, &Call{Method: "go.sql.commit"})
 	return
 }
 
 func (t ocTx) Rollback() (err error) {
-	onDeferWithErr := recordCallStats(context.Background(), "go.sql.rollback", t.options.InstanceName)
-	defer func() {
-		// Invoking this function in a defer so that we can capture
-		// the value of err as set on function exit.
-		onDeferWithErr(err)
-	}()
-
-	_, span := trace.StartSpan(t.ctx, "sql:rollback",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithSampler(t.options.Sampler),
-	)
-	if len(t.options.DefaultAttributes) > 0 {
-		span.AddAttributes(t.options.DefaultAttributes...)
-	}
+	defer t.conn.usage.track(time.Now(), false)
 	defer func() {
-		setSpanStatus(span, t.options, err)
-		span.End()
+		t.end(txOutcomeRollback, err)
 	}()
 
-	err = t.parent.Rollback()
+	_, err = t.options.interceptors.tx(func(context.Context, *Call) (driver.Tx, error) {
+		return nil, t.parent.Rollback()
+	})(t.ctx, &Call{Method: "go.sql.rollback"})
 	return
 }
 
-func paramsAttr(args []driver.Value) []trace.Attribute {
-	attrs := make([]trace.Attribute, 0, len(args))
-	for i, arg := range args {
-		key := "sql.arg" + strconv.Itoa(i)
-		attrs = append(attrs, argToAttr(key, arg))
+func spanName(ctx context.Context, options TraceOptions, method, query string) string {
+	if options.SpanNameFormatter != nil {
+		if name := options.SpanNameFormatter(ctx, method, query); name != "" {
+			return name
+		}
 	}
-	return attrs
+	return method
 }
 
-func namedParamsAttr(args []driver.NamedValue) []trace.Attribute {
-	attrs := make([]trace.Attribute, 0, len(args))
-	for _, arg := range args {
-		var key string
-		if arg.Name != "" {
-			key = arg.Name
-		} else {
-			key = "sql.arg." + strconv.Itoa(arg.Ordinal)
-		}
-		attrs = append(attrs, argToAttr(key, arg.Value))
+// newCall returns the Call of a connection method.
+func newCall(method, query string, args []driver.NamedValue, options TraceOptions) *Call {
+	call := &Call{Method: method, Query: query, Args: args}
+	call.Operation, call.Table = queryOperation(query, options)
+	return call
+}
+
+// namedValues converts the arguments of deprecated driver methods.
+func namedValues(args []driver.Value) []driver.NamedValue {
+	if args == nil {
+		return nil
 	}
-	return attrs
-}
-
-func argToAttr(key string, val interface{}) trace.Attribute {
-	switch v := val.(type) {
-	case nil:
-		return trace.StringAttribute(key, "")
-	case int64:
-		return trace.Int64Attribute(key, v)
-	case float64:
-		return trace.StringAttribute(key, fmt.Sprintf("%f", v))
-	case bool:
-		return trace.BoolAttribute(key, v)
-	case []byte:
-		if len(v) > 256 {
-			v = v[0:256]
-		}
-		return trace.StringAttribute(key, fmt.Sprintf("%s", v))
-	default:
-		s := fmt.Sprintf("%v", v)
-		if len(s) > 256 {
-			s = s[0:256]
-		}
-		return trace.StringAttribute(key, s)
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
 	}
+	return named
 }
 
-func setSpanStatus(span *trace.Span, opts TraceOptions, err error) {
-	var status trace.Status
-	switch err {
-	case nil:
-		status.Code = trace.StatusCodeOK
-		span.SetStatus(status)
-		return
-	case driver.ErrSkip:
-		status.Code = trace.StatusCodeUnimplemented
-		if opts.DisableErrSkip {
-			// Suppress driver.ErrSkip since at runtime some drivers might not have
-			// certain features, and an error would pollute many spans.
-			status.Code = trace.StatusCodeOK
-		}
-	case context.Canceled:
-		status.Code = trace.StatusCodeCancelled
-	case context.DeadlineExceeded:
-		status.Code = trace.StatusCodeDeadlineExceeded
-	case sql.ErrNoRows:
-		status.Code = trace.StatusCodeNotFound
-	case sql.ErrTxDone, errConnDone:
-		status.Code = trace.StatusCodeFailedPrecondition
-	default:
-		status.Code = trace.StatusCodeUnknown
+// values converts arguments back for use with deprecated driver methods.
+func values(args []driver.NamedValue) []driver.Value {
+	if args == nil {
+		return nil
 	}
-	status.Message = err.Error()
-	span.SetStatus(status)
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vals[i] = arg.Value
+	}
+	return vals
+}
+
+// queryOperation returns the operation and main table of query if the
+// Operation option is enabled.
+func queryOperation(query string, options TraceOptions) (operation, table string) {
+	if !options.Operation {
+		return "", ""
+	}
+	return ParseQuery(query, options.Dialect)
+}
+
+func isolationLevelName(level driver.IsolationLevel) string {
+	switch sql.IsolationLevel(level) {
+	case sql.LevelDefault:
+		return "Default"
+	case sql.LevelReadUncommitted:
+		return "Read Uncommitted"
+	case sql.LevelReadCommitted:
+		return "Read Committed"
+	case sql.LevelWriteCommitted:
+		return "Write Committed"
+	case sql.LevelRepeatableRead:
+		return "Repeatable Read"
+	case sql.LevelSnapshot:
+		return "Snapshot"
+	case sql.LevelSerializable:
+		return "Serializable"
+	case sql.LevelLinearizable:
+		return "Linearizable"
+	}
+	return "IsolationLevel(" + strconv.Itoa(int(level)) + ")"
 }
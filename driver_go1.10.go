@@ -6,8 +6,6 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
-
-	"go.opencensus.io/trace"
 )
 
 var errConnDone = sql.ErrConnDone
@@ -21,20 +19,10 @@ var (
 // WrapConnector allows wrapping a database driver.Connector which eliminates
 // the need to register ocsql as an available driver.Driver.
 func WrapConnector(dc driver.Connector, options ...TraceOption) driver.Connector {
-	o := TraceOptions{}
-	for _, option := range options {
-		option(&o)
-	}
-	if o.InstanceName == "" {
-		o.InstanceName = defaultInstanceName
-	} else {
-		o.DefaultAttributes = append(o.DefaultAttributes, trace.StringAttribute("sql.instance", o.InstanceName))
-	}
-
 	return &ocDriver{
 		parent:    dc.Driver(),
 		connector: dc,
-		options:   o,
+		options:   newTraceOptions(dc, options...),
 	}
 }
 
@@ -57,7 +45,7 @@ func wrapConn(parent driver.Conn, options TraceOptions) driver.Conn {
 		n, hasNameValueChecker = parent.(driver.NamedValueChecker)
 		s, hasSessionResetter  = parent.(driver.SessionResetter)
 	)
-	c := &ocConn{parent: parent, options: options}
+	c := newConn(parent, options)
 	switch {
 	case !hasNameValueChecker && !hasSessionResetter:
 		return c
@@ -81,7 +69,7 @@ func wrapConn(parent driver.Conn, options TraceOptions) driver.Conn {
 	panic("unreachable")
 }
 
-func wrapStmt(stmt driver.Stmt, query string, options TraceOptions) driver.Stmt {
+func wrapStmt(stmt driver.Stmt, query string, conn *ocConn) driver.Stmt {
 	var (
 		_, hasExeCtx    = stmt.(driver.StmtExecContext)
 		_, hasQryCtx    = stmt.(driver.StmtQueryContext)
@@ -89,7 +77,8 @@ func wrapStmt(stmt driver.Stmt, query string, options TraceOptions) driver.Stmt
 		n, hasNamValChk = stmt.(driver.NamedValueChecker)
 	)
 
-	s := ocStmt{parent: stmt, query: query, options: options}
+	s := ocStmt{parent: stmt, query: query, options: conn.options, conn: conn}
+	s.operation, s.table = queryOperation(query, conn.options)
 	switch {
 	case !hasExeCtx && !hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
@@ -198,15 +187,16 @@ func (d ocDriver) OpenConnector(name string) (driver.Connector, error) {
 	if err != nil {
 		return nil, err
 	}
+	d.options = dsnOptions(name, d.options)
 	return d, err
 }
 
 func (d ocDriver) Connect(ctx context.Context) (driver.Conn, error) {
-	c, err := d.connector.Connect(ctx)
+	c, err := connect(ctx, d.options, d.connector.Connect)
 	if err != nil {
 		return nil, err
 	}
-	return &ocConn{parent: c, options: d.options}, nil
+	return wrapConn(c, d.options), nil
 }
 
 func (d ocDriver) Driver() driver.Driver {
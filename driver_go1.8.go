@@ -21,11 +21,12 @@ func wrapDriver(d driver.Driver, o TraceOptions) driver.Driver {
 }
 
 func wrapConn(c driver.Conn, options TraceOptions) driver.Conn {
-	return &ocConn{parent: c, options: options}
+	return newConn(c, options)
 }
 
-func wrapStmt(stmt driver.Stmt, query string, options TraceOptions) driver.Stmt {
-	s := ocStmt{parent: stmt, query: query, options: options}
+func wrapStmt(stmt driver.Stmt, query string, conn *ocConn) driver.Stmt {
+	s := ocStmt{parent: stmt, query: query, options: conn.options, conn: conn}
+	s.operation, s.table = queryOperation(query, conn.options)
 	_, hasExeCtx := stmt.(driver.StmtExecContext)
 	_, hasQryCtx := stmt.(driver.StmtQueryContext)
 	c, hasColCnv := stmt.(driver.ColumnConverter)
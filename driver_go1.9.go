@@ -23,7 +23,7 @@ func wrapConn(parent driver.Conn, options TraceOptions) driver.Conn {
 	var (
 		n, hasNameValueChecker = parent.(driver.NamedValueChecker)
 	)
-	c := &ocConn{parent: parent, options: options}
+	c := newConn(parent, options)
 	if hasNameValueChecker {
 		return struct {
 			conn
@@ -33,7 +33,7 @@ func wrapConn(parent driver.Conn, options TraceOptions) driver.Conn {
 	return c
 }
 
-func wrapStmt(stmt driver.Stmt, query string, options TraceOptions) driver.Stmt {
+func wrapStmt(stmt driver.Stmt, query string, conn *ocConn) driver.Stmt {
 	var (
 		_, hasExeCtx    = stmt.(driver.StmtExecContext)
 		_, hasQryCtx    = stmt.(driver.StmtQueryContext)
@@ -41,7 +41,8 @@ func wrapStmt(stmt driver.Stmt, query string, options TraceOptions) driver.Stmt
 		n, hasNamValChk = stmt.(driver.NamedValueChecker)
 	)
 
-	s := ocStmt{parent: stmt, query: query, options: options}
+	s := ocStmt{parent: stmt, query: query, options: conn.options, conn: conn}
+	s.operation, s.table = queryOperation(query, conn.options)
 	switch {
 	case !hasExeCtx && !hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
@@ -7,6 +7,10 @@ import (
 	"io"
 	"reflect"
 	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
 )
 
 var errDummy = errors.New("dummy")
@@ -30,7 +34,7 @@ func TestWrappingTransparency(t *testing.T) {
 	var (
 		ctx   = context.Background()
 		oRows = &stubRows{}
-		wRows = wrapRows(ctx, oRows, AllTraceOptions)
+		wRows = wrapRows(ctx, oRows, "go.sql.query", "", AllTraceOptions, nil)
 	)
 
 	if want, have := oRows.Columns(), wRows.Columns(); len(want) != len(have) {
@@ -84,7 +88,7 @@ func TestWrappingFallback(t *testing.T) {
 	var (
 		ctx   = context.Background()
 		oRows = struct{ driver.Rows }{&stubRows{}}
-		wRows = wrapRows(ctx, oRows, AllTraceOptions)
+		wRows = wrapRows(ctx, oRows, "go.sql.query", "", AllTraceOptions, nil)
 	)
 
 	if want, have := oRows.Columns(), wRows.Columns(); len(want) != len(have) {
@@ -133,3 +137,469 @@ func TestWrappingFallback(t *testing.T) {
 		t.Errorf("rows.ColumnTypePrecisionScale want: %d:%d:%t, have %d:%d:%t", oPrecision, oScale, oOk, wPrecision, wScale, wOk)
 	}
 }
+
+// sliceRows is a result set of n rows. Once exhausted, Next fails with err if
+// set. The first row is delayed by delay.
+type sliceRows struct {
+	n     int64
+	err   error
+	delay time.Duration
+	next  int64
+}
+
+func (r *sliceRows) Columns() []string { return []string{"n"} }
+func (r *sliceRows) Close() error      { return nil }
+
+func (r *sliceRows) Next(dest []driver.Value) error {
+	if r.next == r.n {
+		if r.err != nil {
+			return r.err
+		}
+		return io.EOF
+	}
+	if r.next == 0 {
+		time.Sleep(r.delay)
+	}
+	r.next++
+	dest[0] = r.next
+	return nil
+}
+
+// queryConn is a connection returning rows for each query.
+type queryConn struct {
+	driver.Conn
+	rows driver.Rows
+}
+
+func (c queryConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return c.rows, nil
+}
+
+func TestRows(t *testing.T) {
+	if err := view.Register(SQLClientRowsReturnedView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientRowsReturnedView)
+
+	tests := []struct {
+		name     string
+		rows     *sliceRows
+		read     int
+		wantErr  error
+		wantRows int64
+	}{
+		{name: "rows-eof", rows: &sliceRows{n: 3, delay: 2 * time.Millisecond}, read: 4, wantErr: io.EOF, wantRows: 3},
+		{name: "rows-error", rows: &sliceRows{n: 2, err: errDummy, delay: 2 * time.Millisecond}, read: 3, wantErr: errDummy, wantRows: 2},
+		{name: "rows-close", rows: &sliceRows{n: 3, delay: 2 * time.Millisecond}, read: 1, wantRows: 1},
+		{name: "rows-empty", rows: &sliceRows{}, read: 1, wantErr: io.EOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracer := &recordingTracer{}
+			ctx, _ := tracer.StartSpan(context.Background(), "parent")
+			conn := WrapConn(queryConn{rows: test.rows},
+				WithRows(true), WithTracer(tracer), WithInstanceName(test.name),
+			).(*ocConn)
+
+			rows, err := conn.QueryContext(ctx, "SELECT n FROM t", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dest := make([]driver.Value, 1)
+			for i := 0; i < test.read; i++ {
+				if err = rows.Next(dest); err != nil {
+					break
+				}
+			}
+			if err != test.wantErr {
+				t.Fatalf("want error %v, have: %v", test.wantErr, err)
+			}
+			if test.wantErr != nil {
+				// further calls must not finish the result set again
+				_ = rows.Next(dest)
+			}
+			if err = rows.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err = rows.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var span *recordedSpan
+			for _, s := range tracer.spans {
+				if s.name == "sql:rows" {
+					span = s
+				}
+			}
+			if span == nil || !span.ended {
+				t.Fatalf("want ended sql:rows span, have: %+v", tracer.spans)
+			}
+			if have := span.attrs["sql.rows_returned"]; have != test.wantRows {
+				t.Errorf("want %d rows returned, have: %v", test.wantRows, have)
+			}
+			fetch, _ := span.attrs["sql.fetch_duration_ms"].(float64)
+			firstRow, hasFirstRow := span.attrs["sql.time_to_first_row_ms"].(float64)
+			if hasFirstRow != (test.wantRows > 0) {
+				t.Errorf("want time to first row only if rows are returned, have: %+v", span.attrs)
+			}
+			if hasFirstRow && (firstRow < 2 || fetch < firstRow) {
+				t.Errorf("want time to first row of at least 2ms within fetch duration, have: %vms of %vms", firstRow, fetch)
+			}
+			wantCode := int32(trace.StatusCodeOK)
+			if test.wantErr == errDummy {
+				wantCode = trace.StatusCodeUnknown
+			}
+			if span.status.Code != wantCode {
+				t.Errorf("want status code %d, have: %+v", wantCode, span.status)
+			}
+
+			data, err := view.RetrieveData(SQLClientRowsReturnedView.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var dist *view.DistributionData
+			for _, row := range data {
+				for _, tag := range row.Tags {
+					if tag.Key == GoSQLInstance && tag.Value == test.name {
+						dist = row.Data.(*view.DistributionData)
+					}
+				}
+			}
+			if dist == nil || dist.Count != 1 || dist.Sum() != float64(test.wantRows) {
+				t.Errorf("want a single recording of %d rows, have: %+v", test.wantRows, dist)
+			}
+		})
+	}
+}
+
+// countingResult is a result counting the RowsAffected calls.
+type countingResult struct {
+	n     int64
+	err   error
+	calls int
+}
+
+func (r *countingResult) LastInsertId() (int64, error) { return 0, nil }
+
+func (r *countingResult) RowsAffected() (int64, error) {
+	r.calls++
+	return r.n, r.err
+}
+
+// execConn is a connection returning result for each exec.
+type execConn struct {
+	driver.Conn
+	result driver.Result
+}
+
+func (c execConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return c.result, nil
+}
+
+func TestEagerRowsAffected(t *testing.T) {
+	if err := view.Register(SQLClientRowsAffectedView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientRowsAffectedView)
+
+	tests := []struct {
+		name   string
+		result *countingResult
+		skip   bool
+	}{
+		{name: "affected", result: &countingResult{n: 5}},
+		{name: "affected-error", result: &countingResult{err: errDummy}},
+		{name: "affected-untraced", result: &countingResult{n: 3}, skip: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracer := &recordingTracer{}
+			ctx, _ := tracer.StartSpan(context.Background(), "parent")
+			if test.skip {
+				ctx = SkipTracing(ctx)
+			}
+			conn := WrapConn(execConn{result: test.result},
+				WithEagerRowsAffected(true), WithTracer(tracer), WithInstanceName(test.name),
+			).(*ocConn)
+
+			res, err := conn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.result.calls != 1 {
+				t.Errorf("want RowsAffected called on exec, have: %d calls", test.result.calls)
+			}
+			for i := 0; i < 2; i++ {
+				if n, err := res.RowsAffected(); n != test.result.n || err != test.result.err {
+					t.Errorf("want %d rows affected and error %v, have: %d, %v", test.result.n, test.result.err, n, err)
+				}
+			}
+			if test.result.calls != 1 {
+				t.Errorf("want cached rows affected, have: %d calls", test.result.calls)
+			}
+
+			if _, ok := tracer.spans[0].attrs["sql.rows_affected"]; ok {
+				t.Error("want rows affected not added to the caller span")
+			}
+			if test.skip {
+				if len(tracer.spans) != 1 {
+					t.Errorf("want no sql:exec span, have: %d spans", len(tracer.spans))
+				}
+			} else if span := tracer.spans[1]; span.name != "sql:exec" {
+				t.Errorf("want sql:exec span, have: %s", span.name)
+			} else if have, ok := span.attrs["sql.rows_affected"]; ok != (test.result.err == nil) || (ok && have != test.result.n) {
+				t.Errorf("want rows affected on sql:exec span unless failed, have: %+v", span.attrs)
+			}
+
+			data, err := view.RetrieveData(SQLClientRowsAffectedView.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var dist *view.DistributionData
+			for _, row := range data {
+				for _, tag := range row.Tags {
+					if tag.Key == GoSQLInstance && tag.Value == test.name {
+						dist = row.Data.(*view.DistributionData)
+					}
+				}
+			}
+			if test.result.err != nil {
+				if dist != nil {
+					t.Errorf("want no rows affected recorded on error, have: %+v", dist)
+				}
+			} else if dist == nil || dist.Count != 1 || dist.Sum() != float64(test.result.n) {
+				t.Errorf("want a single recording of %d rows, have: %+v", test.result.n, dist)
+			}
+		})
+	}
+}
+
+// txConn is a connection beginning transactions failing to end with err.
+type txConn struct {
+	stubConn
+	err error
+}
+
+func (c txConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return stubTx{err: c.err}, nil
+}
+
+type stubTx struct{ err error }
+
+func (t stubTx) Commit() error   { return t.err }
+func (t stubTx) Rollback() error { return t.err }
+
+func TestTransactionSpan(t *testing.T) {
+	tests := []struct {
+		name        string
+		statements  int
+		rollback    bool
+		err         error
+		wantOutcome string
+	}{
+		{name: "commit", statements: 2, wantOutcome: txOutcomeCommit},
+		{name: "rollback", statements: 1, rollback: true, wantOutcome: txOutcomeRollback},
+		{name: "error", err: errDummy, wantOutcome: txOutcomeError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracer := &recordingTracer{}
+			ctx, caller := tracer.StartSpan(context.Background(), "caller")
+			conn := WrapConn(txConn{err: test.err}, WithTransaction(true), WithTracer(tracer)).(*ocConn)
+
+			tx, err := conn.BeginTx(ctx, driver.TxOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i := 0; i < test.statements; i++ {
+				if _, err = conn.ExecContext(ctx, "UPDATE t SET a = 1", nil); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if test.rollback {
+				err = tx.Rollback()
+			} else {
+				err = tx.Commit()
+			}
+			if err != test.err {
+				t.Fatalf("want error %v, have: %v", test.err, err)
+			}
+			if _, err = conn.ExecContext(ctx, "UPDATE t SET a = 2", nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var (
+				txSpan *recordedSpan
+				execs  []*recordedSpan
+			)
+			for _, span := range tracer.spans {
+				switch span.name {
+				case "sql:transaction":
+					txSpan = span
+				case "sql:exec":
+					execs = append(execs, span)
+				}
+			}
+			if txSpan == nil || !txSpan.ended || txSpan.parent != caller {
+				t.Fatalf("want ended sql:transaction span child of the caller, have: %+v", txSpan)
+			}
+			if have := txSpan.attrs["sql.tx.statements"]; have != int64(test.statements) {
+				t.Errorf("want %d statements, have: %v", test.statements, have)
+			}
+			if have := txSpan.attrs["sql.tx.outcome"]; have != test.wantOutcome {
+				t.Errorf("want outcome %s, have: %v", test.wantOutcome, have)
+			}
+			if (txSpan.status.Code == trace.StatusCodeOK) != (test.err == nil) {
+				t.Errorf("want status reflecting error %v, have: %+v", test.err, txSpan.status)
+			}
+
+			if len(execs) != test.statements+1 {
+				t.Fatalf("want %d sql:exec spans, have: %d", test.statements+1, len(execs))
+			}
+			for _, span := range execs[:test.statements] {
+				if span.parent != txSpan {
+					t.Errorf("want statement within transaction child of the transaction span, have parent: %+v", span.parent)
+				}
+			}
+			if span := execs[test.statements]; span.parent != caller {
+				t.Errorf("want statement after transaction child of the caller, have parent: %+v", span.parent)
+			}
+		})
+	}
+}
+
+func TestTransactionStats(t *testing.T) {
+	if err := view.Register(SQLClientTxDurationView, SQLClientTxOutcomeView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientTxDurationView, SQLClientTxOutcomeView)
+
+	for _, end := range []struct {
+		err      error
+		rollback bool
+	}{
+		{}, {}, {rollback: true}, {err: errDummy}, {err: errDummy, rollback: true},
+	} {
+		conn := WrapConn(txConn{err: end.err}, WithInstanceName("tx-stats")).(*ocConn)
+		tx, err := conn.BeginTx(context.Background(), driver.TxOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if end.rollback {
+			err = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+		if err != end.err {
+			t.Fatalf("want error %v, have: %v", end.err, err)
+		}
+	}
+
+	want := map[string]int64{txOutcomeCommit: 2, txOutcomeRollback: 1, txOutcomeError: 2}
+	for _, v := range []*view.View{SQLClientTxDurationView, SQLClientTxOutcomeView} {
+		data, err := view.RetrieveData(v.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := map[string]int64{}
+		for _, row := range data {
+			var instance, outcome string
+			for _, tag := range row.Tags {
+				switch tag.Key {
+				case GoSQLInstance:
+					instance = tag.Value
+				case GoSQLTxOutcome:
+					outcome = tag.Value
+				}
+			}
+			if instance != "tx-stats" {
+				continue
+			}
+			switch data := row.Data.(type) {
+			case *view.CountData:
+				have[outcome] = data.Value
+			case *view.DistributionData:
+				have[outcome] = data.Count
+			}
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%s want transactions per outcome %v, have: %v", v.Name, want, have)
+		}
+	}
+}
+
+func TestSpanNameFormatter(t *testing.T) {
+	type formatted struct{ method, query string }
+	var calls []formatted
+
+	tracer := &recordingTracer{}
+	ctx, _ := tracer.StartSpan(context.Background(), "parent")
+	conn := WrapConn(stubConn{},
+		WithPing(true),
+		WithTracer(tracer),
+		WithSpanNameFormatter(func(_ context.Context, method, query string) string {
+			calls = append(calls, formatted{method, query})
+			if query == "" {
+				return ""
+			}
+			return method + " " + query
+		}),
+	).(*ocConn)
+
+	if _, err := conn.ExecContext(ctx, "UPDATE t SET a = 1", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := conn.Ping(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []formatted{{"sql:exec", "UPDATE t SET a = 1"}, {"sql:ping", ""}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("want formatter called with %v, have: %v", want, calls)
+	}
+	if len(tracer.spans) != 3 {
+		t.Fatalf("want parent, exec and ping span, have: %d spans", len(tracer.spans))
+	}
+	if have := tracer.spans[1].name; have != "sql:exec UPDATE t SET a = 1" {
+		t.Errorf("want formatted span name, have: %s", have)
+	}
+	if have := tracer.spans[2].name; have != "sql:ping" {
+		t.Errorf("want default span name, have: %s", have)
+	}
+}
+
+func TestSkippedCallStats(t *testing.T) {
+	if err := view.Register(SQLClientCallsView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientCallsView)
+
+	conn := WrapConn(stubConn{}, WithInstanceName("skipped")).(*ocConn)
+	if _, err := conn.Exec("UPDATE t SET a = 1", nil); err != driver.ErrSkip {
+		t.Fatalf("want error %v, have: %v", driver.ErrSkip, err)
+	}
+	if _, err := conn.Query("SELECT 1", nil); err != driver.ErrSkip {
+		t.Fatalf("want error %v, have: %v", driver.ErrSkip, err)
+	}
+	if _, err := conn.QueryContext(context.Background(), "SELECT 1", nil); err != driver.ErrSkip {
+		t.Fatalf("want error %v, have: %v", driver.ErrSkip, err)
+	}
+
+	data, err := view.RetrieveData(SQLClientCallsView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := map[string]int64{}
+	for _, row := range data {
+		tags := map[string]string{}
+		for _, tag := range row.Tags {
+			tags[tag.Key.Name()] = tag.Value
+		}
+		if tags[GoSQLInstance.Name()] == "skipped" && tags[GoSQLError.Name()] == ErrorClassErrSkip {
+			have[tags[GoSQLMethod.Name()]] = row.Data.(*view.CountData).Value
+		}
+	}
+	if want := map[string]int64{"go.sql.exec": 1, "go.sql.query": 2}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want skipped calls %v, have: %v", want, have)
+	}
+}
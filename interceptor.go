@@ -0,0 +1,192 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// Call describes an intercepted driver call.
+type Call struct {
+	// Method identifies the call, e.g. go.sql.exec, go.sql.stmt.query or
+	// go.sql.commit. It matches the value of the GoSQLMethod stats tag.
+	Method string
+
+	// Query is the sql query the call relates to, if any. For prepared
+	// statements it is informational only, changing it has no effect.
+	Query string
+
+	// Args holds the arguments of exec and query calls.
+	Args []driver.NamedValue
+
+	// Operation and Table hold the operation and main table of Query if the
+	// Operation TraceOption is enabled.
+	Operation string
+	Table     string
+
+	// TxOptions holds the options of go.sql.begin calls.
+	TxOptions driver.TxOptions
+
+	// deprecated describes the deprecated driver feature used by the call.
+	deprecated string
+	// missingContext reports that the parent driver does not accept a context.
+	missingContext bool
+	// tx receives the state of the transaction started by go.sql.begin calls.
+	tx *txState
+}
+
+// The following function types invoke the next interceptor in the chain, or
+// the parent driver if the end of the chain has been reached. An interceptor
+// wishing to modify the call, e.g. to rewrite the query, should pass a
+// modified copy of the Call.
+type (
+	// PingFunc invokes a go.sql.ping call.
+	PingFunc func(ctx context.Context, call *Call) error
+	// ExecFunc invokes a go.sql.exec or go.sql.stmt.exec call.
+	ExecFunc func(ctx context.Context, call *Call) (driver.Result, error)
+	// QueryFunc invokes a go.sql.query or go.sql.stmt.query call.
+	QueryFunc func(ctx context.Context, call *Call) (driver.Rows, error)
+	// PrepareFunc invokes a go.sql.prepare call.
+	PrepareFunc func(ctx context.Context, call *Call) (driver.Stmt, error)
+	// TxFunc invokes a go.sql.begin, go.sql.commit or go.sql.rollback call.
+	// Only go.sql.begin calls return a driver.Tx.
+	TxFunc func(ctx context.Context, call *Call) (driver.Tx, error)
+	// RowsFunc invokes a go.sql.rows.next or go.sql.rows.close call. Dest is
+	// nil for go.sql.rows.close calls.
+	RowsFunc func(ctx context.Context, call *Call, dest []driver.Value) error
+	// ResultFunc invokes a go.sql.last_insert_id or go.sql.rows_affected call.
+	ResultFunc func(ctx context.Context, call *Call) (int64, error)
+)
+
+// The following interceptor types wrap driver calls. An interceptor can
+// inspect the call, decide not to invoke next, invoke it with a different
+// context or call, and inspect or replace its results.
+type (
+	PingInterceptor    func(ctx context.Context, call *Call, next PingFunc) error
+	ExecInterceptor    func(ctx context.Context, call *Call, next ExecFunc) (driver.Result, error)
+	QueryInterceptor   func(ctx context.Context, call *Call, next QueryFunc) (driver.Rows, error)
+	PrepareInterceptor func(ctx context.Context, call *Call, next PrepareFunc) (driver.Stmt, error)
+	TxInterceptor      func(ctx context.Context, call *Call, next TxFunc) (driver.Tx, error)
+	RowsInterceptor    func(ctx context.Context, call *Call, dest []driver.Value, next RowsFunc) error
+	ResultInterceptor  func(ctx context.Context, call *Call, next ResultFunc) (int64, error)
+)
+
+// Interceptors groups the interceptors of the various driver calls. Members
+// left nil do not take part in the chain.
+type Interceptors struct {
+	Ping    PingInterceptor
+	Exec    ExecInterceptor
+	Query   QueryInterceptor
+	Prepare PrepareInterceptor
+	Tx      TxInterceptor
+	Rows    RowsInterceptor
+	Result  ResultInterceptor
+}
+
+// chain holds the interceptors wrapping driver calls, outermost first.
+type chain []Interceptors
+
+// buildChain returns the chain of the built-in stats and tracing interceptors
+// followed by the user provided interceptors and the query rewriting
+// sqlcommenter interceptor.
+func buildChain(o TraceOptions) chain {
+	c := chain{statsInterceptors(o)}
+	if !o.SkipTracing {
+		c = append(c, tracingInterceptors(o))
+	}
+	c = append(c, o.Interceptors...)
+	if o.SQLCommenter != nil {
+		c = append(c, commenterInterceptors(o))
+	}
+	return c
+}
+
+func (c chain) ping(final PingFunc) PingFunc {
+	next := final
+	for i := len(c) - 1; i >= 0; i-- {
+		if ic := c[i].Ping; ic != nil {
+			n := next
+			next = func(ctx context.Context, call *Call) error {
+				return ic(ctx, call, n)
+			}
+		}
+	}
+	return next
+}
+
+func (c chain) exec(final ExecFunc) ExecFunc {
+	next := final
+	for i := len(c) - 1; i >= 0; i-- {
+		if ic := c[i].Exec; ic != nil {
+			n := next
+			next = func(ctx context.Context, call *Call) (driver.Result, error) {
+				return ic(ctx, call, n)
+			}
+		}
+	}
+	return next
+}
+
+func (c chain) query(final QueryFunc) QueryFunc {
+	next := final
+	for i := len(c) - 1; i >= 0; i-- {
+		if ic := c[i].Query; ic != nil {
+			n := next
+			next = func(ctx context.Context, call *Call) (driver.Rows, error) {
+				return ic(ctx, call, n)
+			}
+		}
+	}
+	return next
+}
+
+func (c chain) prepare(final PrepareFunc) PrepareFunc {
+	next := final
+	for i := len(c) - 1; i >= 0; i-- {
+		if ic := c[i].Prepare; ic != nil {
+			n := next
+			next = func(ctx context.Context, call *Call) (driver.Stmt, error) {
+				return ic(ctx, call, n)
+			}
+		}
+	}
+	return next
+}
+
+func (c chain) tx(final TxFunc) TxFunc {
+	next := final
+	for i := len(c) - 1; i >= 0; i-- {
+		if ic := c[i].Tx; ic != nil {
+			n := next
+			next = func(ctx context.Context, call *Call) (driver.Tx, error) {
+				return ic(ctx, call, n)
+			}
+		}
+	}
+	return next
+}
+
+func (c chain) rows(final RowsFunc) RowsFunc {
+	next := final
+	for i := len(c) - 1; i >= 0; i-- {
+		if ic := c[i].Rows; ic != nil {
+			n := next
+			next = func(ctx context.Context, call *Call, dest []driver.Value) error {
+				return ic(ctx, call, dest, n)
+			}
+		}
+	}
+	return next
+}
+
+func (c chain) result(final ResultFunc) ResultFunc {
+	next := final
+	for i := len(c) - 1; i >= 0; i-- {
+		if ic := c[i].Result; ic != nil {
+			n := next
+			next = func(ctx context.Context, call *Call) (int64, error) {
+				return ic(ctx, call, n)
+			}
+		}
+	}
+	return next
+}
@@ -0,0 +1,64 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInterceptorChain(t *testing.T) {
+	var order []string
+	record := func(name string) Interceptors {
+		return Interceptors{
+			Exec: func(ctx context.Context, call *Call, next ExecFunc) (driver.Result, error) {
+				order = append(order, name)
+				c := *call
+				c.Query += " /* " + name + " */"
+				return next(ctx, &c)
+			},
+		}
+	}
+	errDenied := errors.New("denied")
+	deny := Interceptors{
+		Exec: func(ctx context.Context, call *Call, next ExecFunc) (driver.Result, error) {
+			if call.Method == "go.sql.stmt.exec" {
+				return nil, errDenied
+			}
+			return next(ctx, call)
+		},
+	}
+
+	o := newTraceOptions(nil, WithInterceptors(record("first"), deny), WithInterceptors(record("second")))
+
+	var query string
+	exec := o.interceptors.exec(func(_ context.Context, call *Call) (driver.Result, error) {
+		query = call.Query
+		return driver.RowsAffected(1), nil
+	})
+
+	if _, err := exec(context.Background(), &Call{Method: "go.sql.exec", Query: "DELETE FROM t"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order want: %v, have: %v", want, order)
+	}
+	if want := "DELETE FROM t /* first */ /* second */"; query != want {
+		t.Errorf("query want: %q, have: %q", want, query)
+	}
+
+	order, query = nil, ""
+	if _, err := exec(context.Background(), &Call{Method: "go.sql.stmt.exec"}); err != errDenied {
+		t.Errorf("error want: %v, have: %v", errDenied, err)
+	}
+	if len(order) != 1 || query != "" {
+		t.Errorf("want chain to stop after the denying interceptor, have: %v", order)
+	}
+
+	// without interceptors for a call the final function is invoked directly
+	final := func(context.Context, *Call, []driver.Value) error { return nil }
+	if rows := o.interceptors.rows(final); reflect.ValueOf(rows).Pointer() != reflect.ValueOf(final).Pointer() {
+		t.Error("want rows chain to consist of the final function only")
+	}
+}
@@ -0,0 +1,237 @@
+package ocsql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// The following handle kinds are tracked by a LeakDetector.
+const (
+	HandleKindRows = "rows"
+	HandleKindTx   = "tx"
+)
+
+// maxHandleStackDepth limits the number of frames captured for each handle.
+const maxHandleStackDepth = 32
+
+// OpenHandle describes a result set or transaction which has not been closed,
+// committed or rolled back yet.
+type OpenHandle struct {
+	// Kind is either HandleKindRows or HandleKindTx.
+	Kind string
+	// Method is the SQL method which created the handle, e.g. go.sql.query.
+	Method string
+	// Query is the sanitized query of a result set. It is empty for
+	// transactions.
+	Query string
+	// Instance is the InstanceName of the wrapped driver.
+	Instance string
+	// Created is the time the handle was created.
+	Created time.Time
+	// TraceID and SpanID identify the span active when the handle was created.
+	TraceID trace.TraceID
+	SpanID  trace.SpanID
+	// Stack is the call stack which created the handle.
+	Stack string
+}
+
+// Age returns the time elapsed since the handle was created.
+func (h OpenHandle) Age() time.Duration {
+	return time.Since(h.Created)
+}
+
+// LeakDetector keeps track of the result sets and transactions opened through
+// a wrapped driver. Handles still open after the configured threshold are
+// reported once as possible leaks: a sql:leak span is created as child of the
+// span active at their creation, MeasureLeakedHandles is recorded and the
+// callback, if any, is invoked. Use WithLeakDetector to attach a LeakDetector
+// to a wrapped driver.
+type LeakDetector struct {
+	threshold time.Duration
+	onLeak    func(OpenHandle)
+
+	mu      sync.Mutex
+	seq     uint64
+	handles map[*leakHandle]struct{}
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type leakHandle struct {
+	OpenHandle
+	ctx      context.Context
+	options  TraceOptions
+	traced   bool
+	pcs      []uintptr
+	seq      uint64
+	reported bool
+}
+
+// NewLeakDetector returns a LeakDetector which reports handles open for longer
+// than threshold. The onLeak callback, which may be nil, is called for each
+// reported handle and can be used to log leaks. A threshold of zero or less
+// disables reporting; open handles can still be inspected with OpenHandles.
+// Call Stop to release the resources of the LeakDetector.
+func NewLeakDetector(threshold time.Duration, onLeak func(OpenHandle)) *LeakDetector {
+	d := &LeakDetector{
+		threshold: threshold,
+		onLeak:    onLeak,
+		handles:   make(map[*leakHandle]struct{}),
+		stop:      make(chan struct{}),
+	}
+	if threshold > 0 {
+		go d.watch()
+	}
+	return d
+}
+
+// OpenHandles returns the handles currently open, oldest first.
+func (d *LeakDetector) OpenHandles() []OpenHandle {
+	d.mu.Lock()
+	handles := make([]*leakHandle, 0, len(d.handles))
+	for h := range d.handles {
+		handles = append(handles, h)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(handles, func(i, j int) bool {
+		return handles[i].seq < handles[j].seq
+	})
+	open := make([]OpenHandle, len(handles))
+	for i, h := range handles {
+		open[i] = h.info()
+	}
+	return open
+}
+
+// Stop stops the reporting of leaked handles.
+func (d *LeakDetector) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+}
+
+// track registers a new handle created by method.
+func (d *LeakDetector) track(ctx context.Context, kind, method, query string, options TraceOptions) *leakHandle {
+	h := &leakHandle{
+		OpenHandle: OpenHandle{
+			Kind:     kind,
+			Method:   method,
+			Instance: options.InstanceName,
+			Created:  time.Now(),
+		},
+		ctx:     ctx,
+		options: options,
+		pcs:     make([]uintptr, maxHandleStackDepth),
+	}
+	if query != "" {
+		h.Query = SanitizeQuery(query, options.Dialect)
+	}
+	if span := tracerFor(options).FromContext(ctx); span != nil {
+		sc := span.SpanContext()
+		h.TraceID, h.SpanID = sc.TraceID, sc.SpanID
+		h.traced = !options.SkipTracing
+	}
+	// skip runtime.Callers, track and the ocsql method creating the handle
+	h.pcs = h.pcs[:runtime.Callers(3, h.pcs)]
+
+	d.mu.Lock()
+	d.seq++
+	h.seq = d.seq
+	d.handles[h] = struct{}{}
+	d.mu.Unlock()
+	return h
+}
+
+// untrack removes a closed handle.
+func (d *LeakDetector) untrack(h *leakHandle) {
+	d.mu.Lock()
+	delete(d.handles, h)
+	d.mu.Unlock()
+}
+
+func (d *LeakDetector) watch() {
+	interval := d.threshold / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.check()
+		}
+	}
+}
+
+// check reports the handles which exceeded the threshold since the last check.
+func (d *LeakDetector) check() {
+	var leaked []*leakHandle
+	d.mu.Lock()
+	for h := range d.handles {
+		if !h.reported && h.Age() >= d.threshold {
+			h.reported = true
+			leaked = append(leaked, h)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, h := range leaked {
+		h.report(d.onLeak)
+	}
+}
+
+func (h *leakHandle) report(onLeak func(OpenHandle)) {
+	age := h.Age()
+	if h.traced {
+		// the span active at the creation of the handle has usually ended by
+		// now, so the leak is recorded as a span of its own
+		_, span := startSpan(h.ctx, "sql:leak", h.Query, h.options)
+		span.AddAttributes(
+			stringAttr("sql.handle.kind", h.Kind),
+			stringAttr("sql.handle.method", h.Method),
+			float64Attr("sql.handle.age_ms", float64(age.Nanoseconds())/1e6),
+		)
+		span.Annotate(nil, fmt.Sprintf("ocsql: %s open for %s, possible leak", h.Kind, age))
+		span.End()
+	}
+
+	recorderFor(h.options).Record(h.ctx, []tag.Mutator{
+		tag.Insert(GoSQLMethod, h.Method), tag.Insert(GoSQLInstance, h.Instance),
+	}, MeasureLeakedHandles.M(1))
+
+	if onLeak != nil {
+		onLeak(h.info())
+	}
+}
+
+// info returns the OpenHandle with the symbolized creation stack.
+func (h *leakHandle) info() OpenHandle {
+	var buf bytes.Buffer
+	frames := runtime.CallersFrames(h.pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			fmt.Fprintf(&buf, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	info := h.OpenHandle
+	info.Stack = buf.String()
+	return info
+}
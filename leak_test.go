@@ -0,0 +1,123 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestLeakDetector(t *testing.T) {
+	var leaked []OpenHandle
+	d := NewLeakDetector(0, func(h OpenHandle) { leaked = append(leaked, h) })
+	d.threshold = time.Millisecond
+	defer d.Stop()
+
+	// track skips the frame of the ocsql method creating the handle
+	track := func(kind, method, query string) *leakHandle {
+		return d.track(context.Background(), kind, method, query, TraceOptions{})
+	}
+	rows := track(HandleKindRows, "go.sql.query", "SELECT * FROM t WHERE a = 'secret'")
+	tx := track(HandleKindTx, "go.sql.begin", "")
+
+	open := d.OpenHandles()
+	if len(open) != 2 || open[0].Kind != HandleKindRows || open[1].Kind != HandleKindTx {
+		t.Fatalf("unexpected open handles: %+v", open)
+	}
+	if want := "SELECT * FROM t WHERE a = ?"; open[0].Query != want {
+		t.Errorf("query want: %q, have: %q", want, open[0].Query)
+	}
+	if !strings.Contains(open[0].Stack, "TestLeakDetector") {
+		t.Errorf("stack does not contain the creating function:\n%s", open[0].Stack)
+	}
+
+	d.untrack(tx)
+	time.Sleep(2 * time.Millisecond)
+	d.check()
+	d.check()
+
+	if len(leaked) != 1 || leaked[0].Kind != HandleKindRows {
+		t.Fatalf("want a single leaked rows handle, have: %+v", leaked)
+	}
+
+	d.untrack(rows)
+	if open := d.OpenHandles(); len(open) != 0 {
+		t.Errorf("want no open handles, have: %+v", open)
+	}
+}
+
+func TestLeakedRows(t *testing.T) {
+	if err := view.Register(SQLClientLeakedHandlesView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientLeakedHandlesView)
+
+	var leaked []OpenHandle
+	d := NewLeakDetector(0, func(h OpenHandle) { leaked = append(leaked, h) })
+	d.threshold = time.Millisecond
+	defer d.Stop()
+
+	tracer := &recordingTracer{}
+	db := sql.OpenDB(WrapConnector(flakyConnector{conns: new(int)},
+		WithTracer(tracer), WithLeakDetector(d), WithInstanceName("leaked-rows"),
+	))
+	defer db.Close()
+
+	ctx, _ := tracer.StartSpan(context.Background(), "caller")
+	rows, err := db.QueryContext(ctx, "SELECT a FROM t WHERE b = 'secret'")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	d.check()
+
+	if len(leaked) != 1 {
+		t.Fatalf("want a single leaked handle, have: %+v", leaked)
+	}
+	if h := leaked[0]; h.Kind != HandleKindRows || h.Method != "go.sql.query" || h.Query != "SELECT a FROM t WHERE b = ?" {
+		t.Errorf("want leaked rows of the sanitized query, have: %+v", h)
+	}
+	if !strings.Contains(leaked[0].Stack, "TestLeakedRows") {
+		t.Errorf("stack does not contain the creating function:\n%s", leaked[0].Stack)
+	}
+
+	var query, leak *recordedSpan
+	for _, span := range tracer.spans {
+		switch span.name {
+		case "sql:query":
+			query = span
+		case "sql:leak":
+			leak = span
+		}
+	}
+	if query == nil || !query.ended {
+		t.Fatalf("want ended sql:query span, have: %+v", query)
+	}
+	if leak == nil || leak.parent != query || !leak.ended {
+		t.Fatalf("want ended sql:leak span child of the sql:query span, have: %+v", leak)
+	}
+	if leak.attrs["sql.handle.kind"] != HandleKindRows || len(leak.annotations) != 1 {
+		t.Errorf("want sql:leak span describing the leaked rows, have: %+v", leak)
+	}
+	if len(query.annotations) != 0 {
+		t.Errorf("want ended sql:query span left untouched, have: %v", query.annotations)
+	}
+
+	data, err := view.RetrieveData(SQLClientLeakedHandlesView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data[0].Data.(*view.CountData).Value != 1 {
+		t.Errorf("want a single leaked handle recorded, have: %+v", data)
+	}
+
+	if err = rows.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if open := d.OpenHandles(); len(open) != 0 {
+		t.Errorf("want no open handles after closing the rows, have: %+v", open)
+	}
+}
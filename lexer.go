@@ -0,0 +1,290 @@
+package ocsql
+
+// Dialect identifies the SQL dialect used when ocsql needs to tokenize
+// queries, for instance when sanitizing them.
+type Dialect int
+
+// The following SQL dialects are supported.
+const (
+	// DialectGeneric tokenizes queries using ANSI SQL quoting rules.
+	DialectGeneric Dialect = iota
+	// DialectPostgres adds support for dollar-quoted strings, escape strings
+	// and nested block comments.
+	DialectPostgres
+	// DialectMySQL adds support for backtick quoted identifiers, double quoted
+	// strings, backslash escapes and hash comments.
+	DialectMySQL
+	// DialectSQLite adds support for bracket and backtick quoted identifiers.
+	DialectSQLite
+)
+
+type tokenType int
+
+const (
+	tokenSpace       tokenType = iota // whitespace
+	tokenComment                      // line and block comments
+	tokenWord                         // keywords and unquoted identifiers
+	tokenIdentifier                   // quoted identifiers
+	tokenString                       // string literals
+	tokenNumber                       // numeric literals
+	tokenBlob                         // hex and bit string literals
+	tokenPlaceholder                  // bind parameter placeholders
+	tokenPunct                        // operators and punctuation
+)
+
+type token struct {
+	typ  tokenType
+	text string
+}
+
+// isLiteral returns true if the token holds a value which might contain
+// sensitive data.
+func (t token) isLiteral() bool {
+	return t.typ == tokenString || t.typ == tokenNumber || t.typ == tokenBlob
+}
+
+// tokenize splits query into tokens. The concatenation of the text of all
+// returned tokens always equals the original query, even if the query is not
+// valid SQL.
+func tokenize(query string, dialect Dialect) []token {
+	l := lexer{query: query, dialect: dialect}
+	for l.pos < len(l.query) {
+		l.next()
+	}
+	return l.tokens
+}
+
+type lexer struct {
+	query   string
+	dialect Dialect
+	pos     int
+	tokens  []token
+}
+
+func (l *lexer) emit(typ tokenType, end int) {
+	if end > len(l.query) {
+		end = len(l.query)
+	}
+	l.tokens = append(l.tokens, token{typ: typ, text: l.query[l.pos:end]})
+	l.pos = end
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.pos+offset < len(l.query) {
+		return l.query[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) next() {
+	c := l.query[l.pos]
+	switch {
+	case isSpace(c):
+		end := l.pos + 1
+		for end < len(l.query) && isSpace(l.query[end]) {
+			end++
+		}
+		l.emit(tokenSpace, end)
+	case c == '-' && l.peek(1) == '-',
+		c == '#' && l.dialect == DialectMySQL:
+		l.emit(tokenComment, l.lineEnd())
+	case c == '/' && l.peek(1) == '*':
+		l.emit(tokenComment, l.blockCommentEnd())
+	case c == '\'':
+		l.emit(tokenString, l.quotedEnd(l.pos, '\'', l.dialect == DialectMySQL))
+	case c == '"':
+		if l.dialect == DialectMySQL {
+			l.emit(tokenString, l.quotedEnd(l.pos, '"', true))
+		} else {
+			l.emit(tokenIdentifier, l.quotedEnd(l.pos, '"', false))
+		}
+	case c == '`' && l.dialect != DialectPostgres:
+		l.emit(tokenIdentifier, l.quotedEnd(l.pos, '`', false))
+	case c == '[' && l.dialect == DialectSQLite:
+		end := l.pos + 1
+		for end < len(l.query) && l.query[end] != ']' {
+			end++
+		}
+		l.emit(tokenIdentifier, end+1)
+	case c == '$':
+		l.dollar()
+	case c == '?':
+		end := l.pos + 1
+		for end < len(l.query) && isDigit(l.query[end]) {
+			end++
+		}
+		l.emit(tokenPlaceholder, end)
+	case (c == ':' || c == '@') && isWordStart(l.peek(1)):
+		end := l.pos + 1
+		for end < len(l.query) && isWordPart(l.query[end]) {
+			end++
+		}
+		l.emit(tokenPlaceholder, end)
+	case c == ':' && l.peek(1) == ':':
+		l.emit(tokenPunct, l.pos+2)
+	case isDigit(c), c == '.' && isDigit(l.peek(1)):
+		l.number()
+	case isWordStart(c):
+		l.word()
+	default:
+		l.emit(tokenPunct, l.pos+1)
+	}
+}
+
+func (l *lexer) lineEnd() int {
+	end := l.pos
+	for end < len(l.query) && l.query[end] != '\n' {
+		end++
+	}
+	return end
+}
+
+func (l *lexer) blockCommentEnd() int {
+	depth := 0
+	for end := l.pos; end+1 < len(l.query); end++ {
+		switch {
+		case l.query[end] == '/' && l.query[end+1] == '*':
+			if depth == 0 || l.dialect == DialectPostgres {
+				depth++
+			}
+			end++
+		case l.query[end] == '*' && l.query[end+1] == '/':
+			depth--
+			end++
+			if depth == 0 {
+				return end + 1
+			}
+		}
+	}
+	return len(l.query)
+}
+
+// quotedEnd returns the position directly after the closing quote of the
+// quoted section starting at start. A doubled quote character is treated as an
+// escaped quote. If backslash is true, backslash escapes are honored as well.
+func (l *lexer) quotedEnd(start int, quote byte, backslash bool) int {
+	for end := start + 1; end < len(l.query); end++ {
+		switch l.query[end] {
+		case '\\':
+			if backslash {
+				end++
+			}
+		case quote:
+			if end+1 < len(l.query) && l.query[end+1] == quote {
+				end++
+				continue
+			}
+			return end + 1
+		}
+	}
+	return len(l.query)
+}
+
+// dollar handles positional placeholders ($1), SQLite named placeholders
+// ($name) and, for Postgres, dollar-quoted strings ($$...$$ and $tag$...$tag$).
+func (l *lexer) dollar() {
+	if isDigit(l.peek(1)) {
+		end := l.pos + 1
+		for end < len(l.query) && isDigit(l.query[end]) {
+			end++
+		}
+		l.emit(tokenPlaceholder, end)
+		return
+	}
+	if l.dialect == DialectSQLite && isWordStart(l.peek(1)) {
+		end := l.pos + 1
+		for end < len(l.query) && isWordPart(l.query[end]) {
+			end++
+		}
+		l.emit(tokenPlaceholder, end)
+		return
+	}
+	if l.dialect == DialectPostgres || l.dialect == DialectGeneric {
+		end := l.pos + 1
+		for end < len(l.query) && isWordPart(l.query[end]) && l.query[end] != '$' {
+			end++
+		}
+		if end < len(l.query) && l.query[end] == '$' {
+			tag := l.query[l.pos : end+1]
+			for i := end + 1; i+len(tag) <= len(l.query); i++ {
+				if l.query[i:i+len(tag)] == tag {
+					l.emit(tokenString, i+len(tag))
+					return
+				}
+			}
+			l.emit(tokenString, len(l.query))
+			return
+		}
+	}
+	l.emit(tokenPunct, l.pos+1)
+}
+
+func (l *lexer) number() {
+	end := l.pos
+	if l.query[end] == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') && isHexDigit(l.peek(2)) {
+		end += 2
+		for end < len(l.query) && isHexDigit(l.query[end]) {
+			end++
+		}
+		l.emit(tokenBlob, end)
+		return
+	}
+	for end < len(l.query) && (isDigit(l.query[end]) || l.query[end] == '.') {
+		end++
+	}
+	if end < len(l.query) && (l.query[end] == 'e' || l.query[end] == 'E') {
+		exp := end + 1
+		if exp < len(l.query) && (l.query[exp] == '+' || l.query[exp] == '-') {
+			exp++
+		}
+		if exp < len(l.query) && isDigit(l.query[exp]) {
+			end = exp
+			for end < len(l.query) && isDigit(l.query[end]) {
+				end++
+			}
+		}
+	}
+	l.emit(tokenNumber, end)
+}
+
+func (l *lexer) word() {
+	// prefixed string literals: E'...', N'...', X'...' and B'...'
+	if l.peek(1) == '\'' {
+		switch l.query[l.pos] {
+		case 'e', 'E':
+			l.emit(tokenString, l.quotedEnd(l.pos+1, '\'', true))
+			return
+		case 'n', 'N':
+			l.emit(tokenString, l.quotedEnd(l.pos+1, '\'', l.dialect == DialectMySQL))
+			return
+		case 'x', 'X', 'b', 'B':
+			l.emit(tokenBlob, l.quotedEnd(l.pos+1, '\'', false))
+			return
+		}
+	}
+	end := l.pos + 1
+	for end < len(l.query) && isWordPart(l.query[end]) {
+		end++
+	}
+	l.emit(tokenWord, end)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isWordStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c >= 0x80
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || isDigit(c) || c == '$'
+}
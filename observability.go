@@ -2,6 +2,7 @@ package ocsql
 
 import (
 	"context"
+	"database/sql/driver"
 	"time"
 
 	"go.opencensus.io/stats"
@@ -15,10 +16,23 @@ var (
 	GoSQLInstance, _ = tag.NewKey("go_sql_instance")
 	// GoSQLMethod is the SQL method called.
 	GoSQLMethod, _ = tag.NewKey("go_sql_method")
-	// GoSQLError is the error received while calling a SQL method.
+	// GoSQLError is the class of the error received while calling a SQL
+	// method as determined by the configured ErrorClassifier.
 	GoSQLError, _ = tag.NewKey("go_sql_error")
 	// GoSQLStatus identifies success vs. error from the SQL method response.
 	GoSQLStatus, _ = tag.NewKey("go_sql_status")
+	// GoSQLOperation is the operation (e.g. SELECT) of the query sent to the
+	// SQL method. It is only set if the Operation TraceOption is enabled.
+	GoSQLOperation, _ = tag.NewKey("go_sql_operation")
+	// GoSQLTxOutcome is the outcome of a transaction: commit, rollback or
+	// error.
+	GoSQLTxOutcome, _ = tag.NewKey("go_sql_tx_outcome")
+	// GoSQLDatabase is the name of the database accessed. It is only set if
+	// the DatabaseTags TraceOption is enabled.
+	GoSQLDatabase, _ = tag.NewKey("go_sql_database")
+	// GoSQLHost is the host name of the database server. It is only set if the
+	// DatabaseTags TraceOption is enabled.
+	GoSQLHost, _ = tag.NewKey("go_sql_host")
 
 	valueOK  = tag.Insert(GoSQLStatus, "OK")
 	valueErr = tag.Insert(GoSQLStatus, "ERROR")
@@ -34,6 +48,20 @@ var (
 	MeasureWaitDuration      = stats.Float64("go.sql/connections/wait_duration", "The total time blocked waiting for a new connection", stats.UnitMilliseconds)
 	MeasureIdleClosed        = stats.Int64("go.sql/connections/idle_closed", "The total number of connections closed due to SetMaxIdleConns", stats.UnitDimensionless)
 	MeasureLifetimeClosed    = stats.Int64("go.sql/connections/lifetime_closed", "The total number of connections closed due to SetConnMaxLifetime", stats.UnitDimensionless)
+	MeasureIntervalWaitCount = stats.Int64("go.sql/connections/interval_wait_count", "The number of connections waited for since the previous recording", stats.UnitDimensionless)
+	MeasureAvgWaitDuration   = stats.Float64("go.sql/connections/avg_wait_duration", "The average time blocked waiting for a new connection since the previous recording", stats.UnitMilliseconds)
+	MeasureUtilization       = stats.Float64("go.sql/connections/utilization", "The ratio of connections in use to the maximum number of open connections", stats.UnitDimensionless)
+	MeasureSaturated         = stats.Int64("go.sql/connections/saturated", "Whether the pool was saturated (1) or not (0) since the previous recording", stats.UnitDimensionless)
+	MeasureRowsReturned      = stats.Int64("go.sql/rows_returned", "The number of rows returned by a query", stats.UnitDimensionless)
+	MeasureRowsAffected      = stats.Int64("go.sql/rows_affected", "The number of rows affected by an exec", stats.UnitDimensionless)
+	MeasureTxDurationMs      = stats.Float64("go.sql/tx/duration", "The duration of transactions in milliseconds", stats.UnitMilliseconds)
+	MeasureLeakedHandles     = stats.Int64("go.sql/leaked_handles", "The number of result sets and transactions left open longer than the leak threshold", stats.UnitDimensionless)
+	MeasureDialLatencyMs     = stats.Float64("go.sql/connections/dial_latency", "The latency of establishing connections in milliseconds", stats.UnitMilliseconds)
+	MeasureConnectErrors     = stats.Int64("go.sql/connections/connect_errors", "The number of failed attempts to establish a connection", stats.UnitDimensionless)
+	MeasureAcquireLatencyMs  = stats.Float64("go.sql/connections/acquire_latency", "The time between a call and the moment a connection received it in milliseconds", stats.UnitMilliseconds)
+	MeasureConnLifetime      = stats.Float64("go.sql/connections/lifetime", "The time between establishing and closing a connection in seconds", stats.UnitSeconds)
+	MeasureConnBusyTime      = stats.Float64("go.sql/connections/busy_time", "The time a connection spent serving calls over its lifetime in seconds", stats.UnitSeconds)
+	MeasureConnStatements    = stats.Int64("go.sql/connections/statements", "The number of statements executed on a connection over its lifetime", stats.UnitDimensionless)
 )
 
 // Default distributions used by views in this package
@@ -70,6 +98,39 @@ var (
 		100000.0,
 		200000.0,
 		500000.0)
+
+	DefaultRowsDistribution = view.Distribution(
+		1,
+		2,
+		5,
+		10,
+		20,
+		50,
+		100,
+		200,
+		500,
+		1000,
+		2000,
+		5000,
+		10000,
+		20000,
+		50000,
+		100000)
+	DefaultSecondsDistribution = view.Distribution(
+		1,
+		5,
+		10,
+		30,
+		60,
+		120,
+		300,
+		600,
+		1800,
+		3600,
+		7200,
+		14400,
+		28800,
+		86400)
 )
 
 // Package ocsql provides some convenience views.
@@ -81,7 +142,7 @@ var (
 		Description: "The distribution of latencies of various calls in milliseconds",
 		Measure:     MeasureLatencyMs,
 		Aggregation: DefaultMillisecondsDistribution,
-		TagKeys:     []tag.Key{GoSQLInstance, GoSQLMethod, GoSQLError, GoSQLStatus},
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLMethod, GoSQLError, GoSQLStatus, GoSQLOperation, GoSQLDatabase, GoSQLHost},
 	}
 
 	SQLClientCallsView = &view.View{
@@ -89,7 +150,7 @@ var (
 		Description: "The number of various calls of methods",
 		Measure:     MeasureLatencyMs,
 		Aggregation: view.Count(),
-		TagKeys:     []tag.Key{GoSQLInstance, GoSQLMethod, GoSQLError, GoSQLStatus},
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLMethod, GoSQLError, GoSQLStatus, GoSQLOperation, GoSQLDatabase, GoSQLHost},
 	}
 
 	SQLClientOpenConnectionsView = &view.View{
@@ -148,11 +209,139 @@ var (
 		TagKeys:     []tag.Key{GoSQLInstance},
 	}
 
+	SQLClientIntervalWaitCountView = &view.View{
+		Name:        "go.sql/db/connections/interval_wait_count",
+		Description: "The number of connections waited for since the previous recording",
+		Measure:     MeasureIntervalWaitCount,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{GoSQLInstance},
+	}
+
+	SQLClientAvgWaitDurationView = &view.View{
+		Name:        "go.sql/db/connections/avg_wait_duration",
+		Description: "The average time blocked waiting for a new connection since the previous recording",
+		Measure:     MeasureAvgWaitDuration,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{GoSQLInstance},
+	}
+
+	SQLClientUtilizationView = &view.View{
+		Name:        "go.sql/db/connections/utilization",
+		Description: "The ratio of connections in use to the maximum number of open connections",
+		Measure:     MeasureUtilization,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{GoSQLInstance},
+	}
+
+	SQLClientSaturatedView = &view.View{
+		Name:        "go.sql/db/connections/saturated",
+		Description: "Whether all connections were in use or callers waited for a connection since the previous recording",
+		Measure:     MeasureSaturated,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{GoSQLInstance},
+	}
+
+	SQLClientRowsReturnedView = &view.View{
+		Name:        "go.sql/client/rows_returned",
+		Description: "The distribution of the number of rows returned by queries",
+		Measure:     MeasureRowsReturned,
+		Aggregation: DefaultRowsDistribution,
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLMethod},
+	}
+
+	SQLClientRowsAffectedView = &view.View{
+		Name:        "go.sql/client/rows_affected",
+		Description: "The distribution of the number of rows affected by execs",
+		Measure:     MeasureRowsAffected,
+		Aggregation: DefaultRowsDistribution,
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLMethod},
+	}
+
+	SQLClientTxDurationView = &view.View{
+		Name:        "go.sql/tx/duration",
+		Description: "The distribution of transaction durations in milliseconds",
+		Measure:     MeasureTxDurationMs,
+		Aggregation: DefaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLTxOutcome},
+	}
+
+	SQLClientTxOutcomeView = &view.View{
+		Name:        "go.sql/tx/outcome",
+		Description: "The number of transactions by outcome",
+		Measure:     MeasureTxDurationMs,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLTxOutcome},
+	}
+
+	SQLClientLeakedHandlesView = &view.View{
+		Name:        "go.sql/client/leaked_handles",
+		Description: "The number of result sets and transactions reported as possible leaks",
+		Measure:     MeasureLeakedHandles,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLMethod},
+	}
+
+	SQLClientDialLatencyView = &view.View{
+		Name:        "go.sql/db/connections/dial_latency",
+		Description: "The distribution of the latency of establishing connections in milliseconds",
+		Measure:     MeasureDialLatencyMs,
+		Aggregation: DefaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLStatus},
+	}
+
+	SQLClientConnectErrorsView = &view.View{
+		Name:        "go.sql/db/connections/connect_errors",
+		Description: "The number of failed attempts to establish a connection",
+		Measure:     MeasureConnectErrors,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{GoSQLInstance, GoSQLError},
+	}
+
+	SQLClientAcquireLatencyView = &view.View{
+		Name:        "go.sql/db/connections/acquire_latency",
+		Description: "The distribution of the time calls waited for a connection in milliseconds",
+		Measure:     MeasureAcquireLatencyMs,
+		Aggregation: DefaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{GoSQLInstance},
+	}
+
+	SQLClientConnLifetimeView = &view.View{
+		Name:        "go.sql/db/connections/lifetime",
+		Description: "The distribution of the lifetime of closed connections in seconds",
+		Measure:     MeasureConnLifetime,
+		Aggregation: DefaultSecondsDistribution,
+		TagKeys:     []tag.Key{GoSQLInstance},
+	}
+
+	SQLClientConnBusyTimeView = &view.View{
+		Name:        "go.sql/db/connections/busy_time",
+		Description: "The distribution of the time closed connections spent serving calls in seconds",
+		Measure:     MeasureConnBusyTime,
+		Aggregation: DefaultSecondsDistribution,
+		TagKeys:     []tag.Key{GoSQLInstance},
+	}
+
+	SQLClientConnStatementsView = &view.View{
+		Name:        "go.sql/db/connections/statements",
+		Description: "The distribution of the number of statements executed per connection",
+		Measure:     MeasureConnStatements,
+		Aggregation: DefaultRowsDistribution,
+		TagKeys:     []tag.Key{GoSQLInstance},
+	}
+
 	DefaultViews = []*view.View{
 		SQLClientLatencyView, SQLClientCallsView, SQLClientOpenConnectionsView,
 		SQLClientIdleConnectionsView, SQLClientActiveConnectionsView,
 		SQLClientWaitCountView, SQLClientWaitDurationView,
 		SQLClientIdleClosedView, SQLClientLifetimeClosedView,
+		SQLClientIntervalWaitCountView, SQLClientAvgWaitDurationView,
+		SQLClientUtilizationView, SQLClientSaturatedView,
+		SQLClientRowsReturnedView, SQLClientRowsAffectedView,
+		SQLClientTxDurationView, SQLClientTxOutcomeView,
+		SQLClientLeakedHandlesView, SQLClientDialLatencyView,
+		SQLClientConnectErrorsView, SQLClientConnLifetimeView,
+		SQLClientConnBusyTimeView, SQLClientConnStatementsView,
+		SQLClientAcquireLatencyView,
 	}
 )
 
@@ -163,7 +352,62 @@ func RegisterAllViews() {
 	}
 }
 
-func recordCallStats(ctx context.Context, method, instanceName string) func(err error) {
+// statsInterceptors returns the built-in interceptors recording the call
+// stats and delivering slow calls to the configured SlowQuerySink.
+func statsInterceptors(o TraceOptions) Interceptors {
+	return Interceptors{
+		Ping: func(ctx context.Context, call *Call, next PingFunc) (err error) {
+			onDeferWithErr := recordCallStats(ctx, call.Method, "", o)
+			defer func() {
+				onDeferWithErr(err)
+			}()
+			return next(ctx, call)
+		},
+		Exec: func(ctx context.Context, call *Call, next ExecFunc) (res driver.Result, err error) {
+			onDeferWithErr := recordCallStats(ctx, call.Method, call.Operation, o)
+			onSlowQuery := recordSlowQuery(ctx, call.Method, call.Query, call.Args, o)
+			defer func() {
+				// Invoking this function in a defer so that we can capture
+				// the value of err as set on function exit.
+				onDeferWithErr(err)
+				onSlowQuery(err)
+			}()
+			return next(ctx, call)
+		},
+		Query: func(ctx context.Context, call *Call, next QueryFunc) (rows driver.Rows, err error) {
+			onDeferWithErr := recordCallStats(ctx, call.Method, call.Operation, o)
+			onSlowQuery := recordSlowQuery(ctx, call.Method, call.Query, call.Args, o)
+			defer func() {
+				onDeferWithErr(err)
+				onSlowQuery(err)
+			}()
+			return next(ctx, call)
+		},
+		Prepare: func(ctx context.Context, call *Call, next PrepareFunc) (stmt driver.Stmt, err error) {
+			onDeferWithErr := recordCallStats(ctx, call.Method, call.Operation, o)
+			onSlowQuery := recordSlowQuery(ctx, call.Method, call.Query, nil, o)
+			defer func() {
+				onDeferWithErr(err)
+				onSlowQuery(err)
+			}()
+			return next(ctx, call)
+		},
+		Tx: func(ctx context.Context, call *Call, next TxFunc) (tx driver.Tx, err error) {
+			onDeferWithErr := recordCallStats(ctx, call.Method, "", o)
+			onSlowQuery := func(error) {}
+			if call.Method == "go.sql.commit" {
+				onSlowQuery = recordSlowQuery(ctx, call.Method, "", nil, o)
+			}
+			defer func() {
+				onDeferWithErr(err)
+				onSlowQuery(err)
+			}()
+			return next(ctx, call)
+		},
+	}
+}
+
+func recordCallStats(ctx context.Context, method, operation string, options TraceOptions) func(err error) {
 	var tags []tag.Mutator
 	startTime := time.Now()
 
@@ -174,15 +418,83 @@ func recordCallStats(ctx context.Context, method, instanceName string) func(err
 			tags = []tag.Mutator{
 				tag.Insert(GoSQLMethod, method),
 				valueErr,
-				tag.Insert(GoSQLError, err.Error()),
-				tag.Insert(GoSQLInstance, instanceName),
+				tag.Insert(GoSQLError, classifyError(options, err)),
+				tag.Insert(GoSQLInstance, options.InstanceName),
 			}
 		} else {
 			tags = []tag.Mutator{
-				tag.Insert(GoSQLMethod, method), valueOK, tag.Insert(GoSQLInstance, instanceName),
+				tag.Insert(GoSQLMethod, method), valueOK, tag.Insert(GoSQLInstance, options.InstanceName),
 			}
 		}
+		if operation != "" {
+			tags = append(tags, tag.Insert(GoSQLOperation, operation))
+		}
+		tags = append(tags, contextTags(ctx, options)...)
+
+		recorderFor(options).Record(ctx, tags, MeasureLatencyMs.M(timeSpentMs))
+	}
+}
+
+// recordConnectStats records the latency of establishing a connection and,
+// if it failed, the connect error.
+func recordConnectStats(ctx context.Context, startTime time.Time, options TraceOptions, err error) {
+	timeSpentMs := float64(time.Since(startTime).Nanoseconds()) / 1e6
+
+	status := valueOK
+	if err != nil {
+		status = valueErr
+		recorderFor(options).Record(ctx, append([]tag.Mutator{
+			tag.Insert(GoSQLError, classifyError(options, err)),
+			tag.Insert(GoSQLInstance, options.InstanceName),
+		}, contextTags(ctx, options)...), MeasureConnectErrors.M(1))
+	}
+
+	recorderFor(options).Record(ctx, append([]tag.Mutator{
+		status, tag.Insert(GoSQLInstance, options.InstanceName),
+	}, contextTags(ctx, options)...), MeasureDialLatencyMs.M(timeSpentMs))
+}
+
+// recordConnStats records the usage of a connection on closing it.
+func recordConnStats(lifetime time.Duration, statements int64, busy time.Duration, options TraceOptions) {
+	ctx := context.Background()
+	recorderFor(options).Record(ctx, append([]tag.Mutator{
+		tag.Insert(GoSQLInstance, options.InstanceName),
+	}, contextTags(ctx, options)...),
+		MeasureConnLifetime.M(lifetime.Seconds()),
+		MeasureConnBusyTime.M(busy.Seconds()),
+		MeasureConnStatements.M(statements),
+	)
+}
+
+// contextTags returns the database tags, if enabled, followed by the tags
+// provided by TagsFromContext for ctx.
+func contextTags(ctx context.Context, options TraceOptions) []tag.Mutator {
+	var tags []tag.Mutator
+	if options.DatabaseTags {
+		if options.Database.Name != "" {
+			tags = append(tags, tag.Insert(GoSQLDatabase, options.Database.Name))
+		}
+		if options.Database.Host != "" {
+			tags = append(tags, tag.Insert(GoSQLHost, options.Database.Host))
+		}
+	}
+	if options.TagsFromContext != nil {
+		tags = append(tags, options.TagsFromContext(ctx)...)
+	}
+	return tags
+}
+
+func recordTxStats(ctx context.Context, startTime time.Time, outcome string, options TraceOptions) {
+	timeSpentMs := float64(time.Since(startTime).Nanoseconds()) / 1e6
+
+	recorderFor(options).Record(ctx, []tag.Mutator{
+		tag.Insert(GoSQLTxOutcome, outcome), tag.Insert(GoSQLInstance, options.InstanceName),
+	}, MeasureTxDurationMs.M(timeSpentMs))
+}
 
-		_ = stats.RecordWithTags(ctx, tags, MeasureLatencyMs.M(timeSpentMs))
+func classifyError(options TraceOptions, err error) string {
+	if options.ErrorClassifier != nil {
+		return options.ErrorClassifier(err)
 	}
+	return DefaultErrorClassifier(err)
 }
@@ -0,0 +1,324 @@
+package ocsqltest
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+)
+
+// connContext groups the context aware interfaces of a connection.
+type connContext interface {
+	driver.Pinger
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+}
+
+// stmtContext groups the context aware interfaces of a statement.
+type stmtContext interface {
+	driver.StmtExecContext
+	driver.StmtQueryContext
+}
+
+// columnConverter is driver.ColumnConverter under a name not shadowing its
+// method when embedded.
+type columnConverter interface {
+	driver.ColumnConverter
+}
+
+// Compile time assertions
+var (
+	_ driver.Conn              = &conn{}
+	_ connContext              = &conn{}
+	_ driver.NamedValueChecker = &conn{}
+	_ driver.SessionResetter   = &conn{}
+	_ driver.Stmt              = &stmt{}
+	_ stmtContext              = &stmt{}
+	_ driver.ColumnConverter   = &stmt{}
+	_ driver.NamedValueChecker = &stmt{}
+)
+
+// newConn returns a connection of d implementing the interfaces selected by
+// d.
+func newConn(d *Driver) driver.Conn {
+	var (
+		c   = &conn{d: d}
+		ctx = d.interfaces.Context
+		nvc = d.interfaces.NamedValueChecker
+		sr  = d.interfaces.SessionResetter
+	)
+	switch {
+	case !ctx && !nvc && !sr:
+		return struct{ driver.Conn }{c}
+	case ctx && !nvc && !sr:
+		return struct {
+			driver.Conn
+			connContext
+		}{c, c}
+	case !ctx && nvc && !sr:
+		return struct {
+			driver.Conn
+			driver.NamedValueChecker
+		}{c, c}
+	case ctx && nvc && !sr:
+		return struct {
+			driver.Conn
+			connContext
+			driver.NamedValueChecker
+		}{c, c, c}
+	case !ctx && !nvc && sr:
+		return struct {
+			driver.Conn
+			driver.SessionResetter
+		}{c, c}
+	case ctx && !nvc && sr:
+		return struct {
+			driver.Conn
+			connContext
+			driver.SessionResetter
+		}{c, c, c}
+	case !ctx && nvc && sr:
+		return struct {
+			driver.Conn
+			driver.NamedValueChecker
+			driver.SessionResetter
+		}{c, c, c}
+	case ctx && nvc && sr:
+		return struct {
+			driver.Conn
+			connContext
+			driver.NamedValueChecker
+			driver.SessionResetter
+		}{c, c, c, c}
+	}
+	panic("unreachable")
+}
+
+// conn implements driver.Conn and all optional interfaces of connections.
+type conn struct {
+	d *Driver
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	_, err := c.d.call(ctx, MethodPing, KeyPing, nil)
+	return err
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r, err := c.d.call(ctx, MethodExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(r), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r, err := c.d.call(ctx, MethodQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: r.Columns, values: r.Rows}, nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if _, err := c.d.call(ctx, MethodPrepare, query, nil); err != nil {
+		return nil, err
+	}
+	return newStmt(c.d, query), nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.d.call(ctx, MethodBegin, KeyBegin, nil); err != nil {
+		return nil, err
+	}
+	return tx{c.d}, nil
+}
+
+func (c *conn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *conn) ResetSession(context.Context) error {
+	c.d.record(MethodResetSession, "")
+	return nil
+}
+
+func (c *conn) Close() error {
+	c.d.record(MethodClose, "")
+	return nil
+}
+
+// newStmt returns a statement of d for query implementing the interfaces
+// selected by d.
+func newStmt(d *Driver, query string) driver.Stmt {
+	var (
+		s   = &stmt{d: d, query: query}
+		ctx = d.interfaces.Context
+		cc  = d.interfaces.ColumnConverter
+		nvc = d.interfaces.NamedValueChecker
+	)
+	switch {
+	case !ctx && !cc && !nvc:
+		return struct{ driver.Stmt }{s}
+	case ctx && !cc && !nvc:
+		return struct {
+			driver.Stmt
+			stmtContext
+		}{s, s}
+	case !ctx && cc && !nvc:
+		return struct {
+			driver.Stmt
+			columnConverter
+		}{s, s}
+	case ctx && cc && !nvc:
+		return struct {
+			driver.Stmt
+			stmtContext
+			columnConverter
+		}{s, s, s}
+	case !ctx && !cc && nvc:
+		return struct {
+			driver.Stmt
+			driver.NamedValueChecker
+		}{s, s}
+	case ctx && !cc && nvc:
+		return struct {
+			driver.Stmt
+			stmtContext
+			driver.NamedValueChecker
+		}{s, s, s}
+	case !ctx && cc && nvc:
+		return struct {
+			driver.Stmt
+			columnConverter
+			driver.NamedValueChecker
+		}{s, s, s}
+	case ctx && cc && nvc:
+		return struct {
+			driver.Stmt
+			stmtContext
+			columnConverter
+			driver.NamedValueChecker
+		}{s, s, s, s}
+	}
+	panic("unreachable")
+}
+
+// stmt implements driver.Stmt and all optional interfaces of statements.
+type stmt struct {
+	d     *Driver
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	r, err := s.d.call(ctx, MethodStmtExec, s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(r), nil
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	r, err := s.d.call(ctx, MethodStmtQuery, s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: r.Columns, values: r.Rows}, nil
+}
+
+func (s *stmt) ColumnConverter(int) driver.ValueConverter {
+	return driver.DefaultParameterConverter
+}
+
+func (s *stmt) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+// tx implements driver.Tx.
+type tx struct {
+	d *Driver
+}
+
+func (t tx) Commit() error {
+	_, err := t.d.call(context.Background(), MethodCommit, KeyCommit, nil)
+	return err
+}
+
+func (t tx) Rollback() error {
+	_, err := t.d.call(context.Background(), MethodRollback, KeyRollback, nil)
+	return err
+}
+
+// result implements driver.Result.
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func newResult(r Result) result {
+	return result{lastInsertID: r.LastInsertID, rowsAffected: r.RowsAffected}
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// rows implements driver.Rows.
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	namedArgs := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		namedArgs = append(namedArgs, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+	return namedArgs
+}
@@ -0,0 +1,202 @@
+// Package ocsqltest provides a fake database driver and in-memory collectors of
+// spans and view data for testing code instrumented with ocsql.
+//
+// The fake Driver serves scripted results, rows, errors and latencies and
+// implements a selectable set of the optional database/sql/driver interfaces:
+//
+//	d := ocsqltest.NewDriver(ocsqltest.Interfaces{Context: true})
+//	d.Script("SELECT name FROM users", ocsqltest.Result{
+//		Columns: []string{"name"},
+//		Rows:    [][]driver.Value{{"alice"}, {"bob"}},
+//	})
+//	db := sql.OpenDB(ocsql.WrapConnector(d.Connector(), ocsql.WithAllowRoot(true)))
+//
+// The package does not depend on ocsql, so it can be used by the tests of any
+// package, including ocsql itself.
+package ocsqltest
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"time"
+)
+
+// The following keys script the results of calls without a query.
+const (
+	KeyConnect  = "CONNECT"
+	KeyPing     = "PING"
+	KeyBegin    = "BEGIN"
+	KeyCommit   = "COMMIT"
+	KeyRollback = "ROLLBACK"
+)
+
+// The following methods are recorded in the Calls of a Driver.
+const (
+	MethodConnect      = "connect"
+	MethodPing         = "ping"
+	MethodExec         = "exec"
+	MethodQuery        = "query"
+	MethodPrepare      = "prepare"
+	MethodStmtExec     = "stmt.exec"
+	MethodStmtQuery    = "stmt.query"
+	MethodBegin        = "begin"
+	MethodCommit       = "commit"
+	MethodRollback     = "rollback"
+	MethodResetSession = "reset_session"
+	MethodClose        = "close"
+)
+
+// Result is the scripted outcome of a call.
+type Result struct {
+	// Columns and Rows are returned by queries.
+	Columns []string
+	Rows    [][]driver.Value
+
+	// RowsAffected and LastInsertID are returned by execs.
+	RowsAffected int64
+	LastInsertID int64
+
+	// Err, if set, is returned by the call.
+	Err error
+
+	// Latency delays the call. Calls taking a context return early with the
+	// error of the context if it is done before.
+	Latency time.Duration
+}
+
+// Call is a call received by a Driver.
+type Call struct {
+	Method string
+	Query  string
+	Args   []driver.NamedValue
+}
+
+// Interfaces selects the optional database/sql/driver interfaces implemented
+// by the connections and statements of a Driver.
+type Interfaces struct {
+	// Context enables Pinger, ExecerContext, QueryerContext,
+	// ConnPrepareContext and ConnBeginTx on connections and StmtExecContext
+	// and StmtQueryContext on statements. Without it, database/sql prepares a
+	// statement for every exec and query.
+	Context bool
+
+	// NamedValueChecker enables NamedValueChecker on connections and
+	// statements, accepting arguments of any type.
+	NamedValueChecker bool
+
+	// SessionResetter enables SessionResetter on connections.
+	SessionResetter bool
+
+	// ColumnConverter enables ColumnConverter on statements.
+	ColumnConverter bool
+}
+
+// Driver is a fake driver.Driver and driver.DriverContext. Its connections
+// serve the results scripted for the queries and keys received, or empty
+// results if nothing was scripted. Preparing a statement takes the latency
+// and returns the error scripted for its query, as does every execution of
+// the statement. Without the Context interfaces, database/sql prepares a
+// statement for every exec and query, so scripted errors are returned by the
+// prepare.
+type Driver struct {
+	interfaces Interfaces
+
+	mu      sync.Mutex
+	results map[string]Result
+	calls   []Call
+}
+
+// NewDriver returns a Driver implementing the selected interfaces.
+func NewDriver(interfaces Interfaces) *Driver {
+	return &Driver{interfaces: interfaces, results: make(map[string]Result)}
+}
+
+// Script sets the result of calls with query, or with one of the keys of
+// calls without a query.
+func (d *Driver) Script(query string, result Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.results[query] = result
+}
+
+// Calls returns the calls received by the Driver in order.
+func (d *Driver) Calls() []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]Call(nil), d.calls...)
+}
+
+// Reset clears the scripted results and the received calls.
+func (d *Driver) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.results = make(map[string]Result)
+	d.calls = nil
+}
+
+// Open implements driver.Driver.
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	return d.connect(context.Background())
+}
+
+// OpenConnector implements driver.DriverContext.
+func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
+	return connector{d}, nil
+}
+
+// Connector returns a driver.Connector of the Driver.
+func (d *Driver) Connector() driver.Connector {
+	return connector{d}
+}
+
+func (d *Driver) connect(ctx context.Context) (driver.Conn, error) {
+	if _, err := d.call(ctx, MethodConnect, KeyConnect, nil); err != nil {
+		return nil, err
+	}
+	return newConn(d), nil
+}
+
+// call records the call and returns its scripted result after the scripted
+// latency.
+func (d *Driver) call(ctx context.Context, method, query string, args []driver.NamedValue) (Result, error) {
+	d.mu.Lock()
+	d.calls = append(d.calls, Call{Method: method, Query: query, Args: args})
+	r := d.results[query]
+	d.mu.Unlock()
+
+	if r.Latency > 0 {
+		t := time.NewTimer(r.Latency)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return r, ctx.Err()
+		}
+	}
+	return r, r.Err
+}
+
+// record records a call which can not be scripted.
+func (d *Driver) record(method, query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.calls = append(d.calls, Call{Method: method, Query: query})
+}
+
+// connector implements driver.Connector.
+type connector struct {
+	d *Driver
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.d.connect(ctx)
+}
+
+func (c connector) Driver() driver.Driver {
+	return c.d
+}
@@ -0,0 +1,147 @@
+package ocsqltest_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"contrib.go.opencensus.io/integrations/ocsql"
+	"contrib.go.opencensus.io/integrations/ocsql/ocsqltest"
+)
+
+func TestInterfaces(t *testing.T) {
+	for _, interfaces := range []ocsqltest.Interfaces{
+		{},
+		{Context: true},
+		{NamedValueChecker: true, SessionResetter: true},
+		{Context: true, NamedValueChecker: true, SessionResetter: true, ColumnConverter: true},
+	} {
+		d := ocsqltest.NewDriver(interfaces)
+		conn, err := d.Open("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stmt, err := conn.Prepare("SELECT 1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, hasExecCtx := conn.(driver.ExecerContext)
+		_, hasStmtExecCtx := stmt.(driver.StmtExecContext)
+		_, hasNamedValueChecker := conn.(driver.NamedValueChecker)
+		_, hasSessionResetter := conn.(driver.SessionResetter)
+		_, hasColumnConverter := stmt.(driver.ColumnConverter)
+		have := ocsqltest.Interfaces{
+			Context:           hasExecCtx && hasStmtExecCtx,
+			NamedValueChecker: hasNamedValueChecker,
+			SessionResetter:   hasSessionResetter,
+			ColumnConverter:   hasColumnConverter,
+		}
+		if have != interfaces {
+			t.Errorf("want interfaces %+v, have: %+v", interfaces, have)
+		}
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	errFailed := errors.New("failed")
+	d := ocsqltest.NewDriver(ocsqltest.Interfaces{Context: true})
+	d.Script("INSERT INTO users", ocsqltest.Result{Err: errFailed})
+
+	conn, err := d.Open("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = conn.Prepare("INSERT INTO users"); err != errFailed {
+		t.Fatalf("want error %v, have: %v", errFailed, err)
+	}
+	if _, err = conn.Prepare("SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := d.Calls()
+	if len(calls) != 3 || calls[1].Method != ocsqltest.MethodPrepare || calls[1].Query != "INSERT INTO users" {
+		t.Errorf("want connect and prepare calls, have: %+v", calls)
+	}
+}
+
+func TestInstrumentation(t *testing.T) {
+	spans, stop := ocsqltest.RecordSpans()
+	defer stop()
+	views, err := ocsqltest.CollectViews(ocsql.SQLClientCallsView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer views.Close()
+
+	errFailed := errors.New("failed")
+	d := ocsqltest.NewDriver(ocsqltest.Interfaces{Context: true})
+	d.Script("SELECT name FROM users", ocsqltest.Result{
+		Columns: []string{"name"},
+		Rows:    [][]driver.Value{{"alice"}, {"bob"}},
+	})
+	d.Script("DELETE FROM users", ocsqltest.Result{Err: errFailed})
+
+	db := sql.OpenDB(ocsql.WrapConnector(d.Connector(),
+		ocsql.WithAllowRoot(true),
+		ocsql.WithSampler(trace.AlwaysSample()),
+		ocsql.WithInstanceName("ocsqltest"),
+	))
+	defer db.Close()
+
+	var names []string
+	rows, err := db.QueryContext(context.Background(), "SELECT name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		names = append(names, name)
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(names) != 2 || names[0] != "alice" || names[1] != "bob" {
+		t.Errorf("want scripted rows, have: %v", names)
+	}
+	if _, err = db.ExecContext(context.Background(), "DELETE FROM users"); err != errFailed {
+		t.Fatalf("want error %v, have: %v", errFailed, err)
+	}
+
+	calls := d.Calls()
+	if len(calls) != 3 || calls[0].Method != ocsqltest.MethodConnect || calls[2].Query != "DELETE FROM users" {
+		t.Errorf("want connect, query and exec calls, have: %+v", calls)
+	}
+
+	recorded := spans.Spans()
+	if len(recorded) != 2 {
+		t.Fatalf("want 2 spans, have: %v", spans.Names())
+	}
+	if s := recorded[0]; s.Name != "sql:query" || s.Status.Code != trace.StatusCodeOK {
+		t.Errorf("want successful sql:query span, have: %s %+v", s.Name, s.Status)
+	}
+	if s := recorded[1]; s.Name != "sql:exec" || s.Status.Code != trace.StatusCodeUnknown {
+		t.Errorf("want failed sql:exec span, have: %s %+v", s.Name, s.Status)
+	}
+
+	row, err := views.Row(ocsql.SQLClientCallsView.Name,
+		tag.Tag{Key: ocsql.GoSQLInstance, Value: "ocsqltest"},
+		tag.Tag{Key: ocsql.GoSQLMethod, Value: "go.sql.query"},
+		tag.Tag{Key: ocsql.GoSQLStatus, Value: "OK"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row == nil || row.Data.(*view.CountData).Value != 1 {
+		t.Errorf("want a single successful query, have: %+v", row)
+	}
+}
@@ -0,0 +1,123 @@
+package ocsqltest
+
+import (
+	"sync"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// SpanRecorder is an in-memory trace.Exporter keeping the exported spans.
+// Only sampled spans are exported, so the spans to record are to be sampled,
+// e.g. using the Sampler TraceOption of ocsql with trace.AlwaysSample().
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+// RecordSpans registers a SpanRecorder as trace exporter until the returned
+// function is called.
+func RecordSpans() (r *SpanRecorder, fnStop func()) {
+	r = &SpanRecorder{}
+	trace.RegisterExporter(r)
+	return r, func() {
+		trace.UnregisterExporter(r)
+	}
+}
+
+// ExportSpan implements trace.Exporter.
+func (r *SpanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = append(r.spans, s)
+}
+
+// Spans returns the recorded spans in the order they ended.
+func (r *SpanRecorder) Spans() []*trace.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*trace.SpanData(nil), r.spans...)
+}
+
+// Names returns the names of the recorded spans in the order they ended.
+func (r *SpanRecorder) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.spans))
+	for _, s := range r.spans {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Reset discards the recorded spans.
+func (r *SpanRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = nil
+}
+
+// ViewCollector collects the data of views from the in-memory store of
+// OpenCensus, without waiting for the data to be exported.
+type ViewCollector struct {
+	views []*view.View
+}
+
+// CollectViews registers views, which start collecting data from scratch,
+// and returns a ViewCollector of their data. Close unregisters the views.
+func CollectViews(views ...*view.View) (*ViewCollector, error) {
+	if err := view.Register(views...); err != nil {
+		return nil, err
+	}
+	return &ViewCollector{views: views}, nil
+}
+
+// Rows returns the rows of the view named name.
+func (c *ViewCollector) Rows(name string) ([]*view.Row, error) {
+	return view.RetrieveData(name)
+}
+
+// Row returns the row of the view named name with exactly the provided tags,
+// or nil if the view has no such row.
+func (c *ViewCollector) Row(name string, tags ...tag.Tag) (*view.Row, error) {
+	rows, err := view.RetrieveData(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if sameTags(row.Tags, tags) {
+			return row, nil
+		}
+	}
+	return nil, nil
+}
+
+// Close unregisters the views, discarding their data.
+func (c *ViewCollector) Close() {
+	view.Unregister(c.views...)
+}
+
+// sameTags reports whether a and b hold the same tags in any order.
+func sameTags(a, b []tag.Tag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, t := range a {
+		found := false
+		for _, u := range b {
+			if t == u {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,278 @@
+package ocsql
+
+import (
+	"context"
+	"strings"
+)
+
+// The following operations are returned by ParseQuery.
+const (
+	OperationSelect   = "SELECT"
+	OperationInsert   = "INSERT"
+	OperationUpdate   = "UPDATE"
+	OperationDelete   = "DELETE"
+	OperationReplace  = "REPLACE"
+	OperationMerge    = "MERGE"
+	OperationCall     = "CALL"
+	OperationDDL      = "DDL"
+	OperationSet      = "SET"
+	OperationShow     = "SHOW"
+	OperationExplain  = "EXPLAIN"
+	OperationBegin    = "BEGIN"
+	OperationCommit   = "COMMIT"
+	OperationRollback = "ROLLBACK"
+	OperationOther    = "OTHER"
+)
+
+// operations maps leading statement keywords to their operation. The set of
+// operations is intentionally small so it can be used as a stats tag.
+var operations = map[string]string{
+	"SELECT":   OperationSelect,
+	"INSERT":   OperationInsert,
+	"UPDATE":   OperationUpdate,
+	"DELETE":   OperationDelete,
+	"REPLACE":  OperationReplace,
+	"MERGE":    OperationMerge,
+	"CALL":     OperationCall,
+	"EXEC":     OperationCall,
+	"EXECUTE":  OperationCall,
+	"CREATE":   OperationDDL,
+	"ALTER":    OperationDDL,
+	"DROP":     OperationDDL,
+	"TRUNCATE": OperationDDL,
+	"RENAME":   OperationDDL,
+	"COMMENT":  OperationDDL,
+	"SET":      OperationSet,
+	"SHOW":     OperationShow,
+	"EXPLAIN":  OperationExplain,
+	"BEGIN":    OperationBegin,
+	"START":    OperationBegin,
+	"COMMIT":   OperationCommit,
+	"END":      OperationCommit,
+	"ROLLBACK": OperationRollback,
+}
+
+// ParseQuery extracts the operation (one of the Operation constants) and the
+// main table of the first statement found in query. Common table expressions
+// are skipped to find the operation of the main statement. Quoted identifiers
+// are returned unquoted and schema qualified table names are returned joined
+// by a dot. If no table can be determined an empty string is returned for it.
+// The literal values in query are never inspected.
+func ParseQuery(query string, dialect Dialect) (operation, table string) {
+	var stmt []token
+	for _, t := range tokenize(query, dialect) {
+		switch {
+		case t.typ == tokenSpace || t.typ == tokenComment:
+			continue
+		case t.typ == tokenPunct && t.text == ";":
+			if operation, table = parseStatement(stmt); operation != "" {
+				return operation, table
+			}
+			stmt = stmt[:0]
+			continue
+		}
+		stmt = append(stmt, t)
+	}
+	return parseStatement(stmt)
+}
+
+// OperationSpanNameFormatter returns a function usable as SpanNameFormatter
+// which names query related spans after the operation and main table of the
+// query, e.g. "SELECT users". Spans without query keep their default name.
+func OperationSpanNameFormatter(dialect Dialect) func(ctx context.Context, method, query string) string {
+	return func(_ context.Context, method, query string) string {
+		if query == "" {
+			return ""
+		}
+		operation, table := ParseQuery(query, dialect)
+		if table == "" {
+			return operation
+		}
+		return operation + " " + table
+	}
+}
+
+func parseStatement(stmt []token) (operation, table string) {
+	p := stmtParser{tokens: stmt}
+	for p.is("(") {
+		p.pos++
+	}
+	if p.isWord("WITH") {
+		p.pos++
+		p.skipCTEs()
+	}
+	if p.pos >= len(p.tokens) {
+		return "", ""
+	}
+	verb := strings.ToUpper(p.tokens[p.pos].text)
+	operation, ok := operations[verb]
+	if !ok || p.tokens[p.pos].typ != tokenWord {
+		return OperationOther, ""
+	}
+	p.pos++
+
+	switch operation {
+	case OperationSelect:
+		if p.seek("FROM") {
+			p.skipWords("ONLY", "LATERAL")
+			table = p.name()
+		}
+	case OperationDelete:
+		start := p.pos
+		if !p.seek("FROM") {
+			p.pos = start
+		}
+		p.skipWords("ONLY")
+		table = p.name()
+	case OperationInsert, OperationReplace:
+		start := p.pos
+		if p.seek("INTO") {
+			table = p.name()
+		} else {
+			p.pos = start
+			p.skipWords("LOW_PRIORITY", "DELAYED", "HIGH_PRIORITY", "IGNORE")
+			table = p.name()
+		}
+	case OperationUpdate:
+		p.skipWords("LOW_PRIORITY", "IGNORE", "ONLY", "OR", "ROLLBACK", "ABORT", "REPLACE", "FAIL")
+		table = p.name()
+	case OperationMerge:
+		if p.seek("INTO") {
+			table = p.name()
+		}
+	case OperationDDL:
+		start := p.pos
+		switch {
+		case verb == "TRUNCATE":
+			p.skipWords("TABLE", "ONLY")
+			table = p.name()
+		case p.seek("TABLE"):
+			p.skipWords("IF", "NOT", "EXISTS", "ONLY")
+			table = p.name()
+		default:
+			// CREATE INDEX ... ON table
+			if p.pos = start; p.seek("ON") {
+				p.skipWords("ONLY")
+				table = p.name()
+			}
+		}
+	}
+	return operation, table
+}
+
+// stmtParser is a minimal cursor over the significant tokens of a statement.
+type stmtParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *stmtParser) is(text string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].text == text
+}
+
+func (p *stmtParser) isWord(word string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].typ == tokenWord &&
+		strings.EqualFold(p.tokens[p.pos].text, word)
+}
+
+// skipParens skips a balanced parenthesized section if found at the cursor.
+func (p *stmtParser) skipParens() {
+	if !p.is("(") {
+		return
+	}
+	depth := 0
+	for ; p.pos < len(p.tokens); p.pos++ {
+		switch p.tokens[p.pos].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				p.pos++
+				return
+			}
+		}
+	}
+}
+
+// skipCTEs skips the common table expressions following a WITH keyword.
+func (p *stmtParser) skipCTEs() {
+	p.skipWords("RECURSIVE")
+	for p.pos < len(p.tokens) {
+		p.pos++ // expression name
+		p.skipParens()
+		p.skipWords("AS", "NOT", "MATERIALIZED")
+		p.skipParens()
+		if !p.is(",") {
+			return
+		}
+		p.pos++
+	}
+}
+
+// skipWords advances the cursor as long as it points to one of words.
+func (p *stmtParser) skipWords(words ...string) {
+	for p.pos < len(p.tokens) {
+		found := false
+		for _, word := range words {
+			if p.isWord(word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+		p.pos++
+	}
+}
+
+// seek advances the cursor to directly after the first occurrence of word
+// which is not nested in parentheses.
+func (p *stmtParser) seek(word string) bool {
+	depth := 0
+	for ; p.pos < len(p.tokens); p.pos++ {
+		switch {
+		case p.is("("):
+			depth++
+		case p.is(")"):
+			depth--
+		case depth == 0 && p.isWord(word):
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+// name returns the, possibly schema qualified, identifier at the cursor.
+func (p *stmtParser) name() string {
+	var parts []string
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		if t.typ != tokenWord && t.typ != tokenIdentifier {
+			break
+		}
+		parts = append(parts, unquoteIdentifier(t.text))
+		p.pos++
+		if !p.is(".") {
+			break
+		}
+		p.pos++
+	}
+	return strings.Join(parts, ".")
+}
+
+func unquoteIdentifier(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	switch s[0] {
+	case '"', '`':
+		q := s[:1]
+		return strings.Replace(strings.TrimSuffix(s[1:], q), q+q, q, -1)
+	case '[':
+		return strings.TrimSuffix(s[1:], "]")
+	}
+	return s
+}
@@ -0,0 +1,42 @@
+package ocsql
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		dialect   Dialect
+		query     string
+		operation string
+		table     string
+	}{
+		{DialectGeneric, "SELECT * FROM users WHERE id = 1", OperationSelect, "users"},
+		{DialectGeneric, "  -- leading comment\n/* x */ select count(*) from public.users u", OperationSelect, "public.users"},
+		{DialectGeneric, "SELECT EXTRACT(YEAR FROM d), (SELECT 1 FROM x) FROM orders", OperationSelect, "orders"},
+		{DialectGeneric, "SELECT 1", OperationSelect, ""},
+		{DialectGeneric, "INSERT INTO accounts (id, name) VALUES (?, ?)", OperationInsert, "accounts"},
+		{DialectGeneric, "UPDATE ONLY items SET x = 1", OperationUpdate, "items"},
+		{DialectGeneric, "DELETE FROM sessions WHERE expired", OperationDelete, "sessions"},
+		{DialectGeneric, "CREATE TABLE IF NOT EXISTS logs (id int)", OperationDDL, "logs"},
+		{DialectGeneric, "CREATE UNIQUE INDEX idx ON events (id)", OperationDDL, "events"},
+		{DialectGeneric, "TRUNCATE TABLE audit", OperationDDL, "audit"},
+		{DialectGeneric, "CALL refresh_stats()", OperationCall, ""},
+		{DialectGeneric, "VACUUM", OperationOther, ""},
+		{DialectGeneric, "", "", ""},
+		{
+			DialectGeneric,
+			"WITH RECURSIVE t(n) AS (SELECT 1 FROM seed UNION SELECT n+1 FROM t), u AS (SELECT 2) INSERT INTO numbers SELECT n FROM t",
+			OperationInsert, "numbers",
+		},
+		{DialectGeneric, "; ; UPDATE a SET b = 1; DELETE FROM c", OperationUpdate, "a"},
+		{DialectPostgres, `SELECT * FROM "My Schema"."User ""Data"""`, OperationSelect, `My Schema.User "Data"`},
+		{DialectMySQL, "INSERT IGNORE `db`.`t` SET a = 'FROM x'", OperationInsert, "db.t"},
+		{DialectSQLite, "SELECT * FROM [order details]", OperationSelect, "order details"},
+	}
+
+	for _, test := range tests {
+		operation, table := ParseQuery(test.query, test.dialect)
+		if operation != test.operation || table != test.table {
+			t.Errorf("ParseQuery(%q) want: %q %q, have: %q %q", test.query, test.operation, test.table, operation, table)
+		}
+	}
+}
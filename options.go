@@ -1,6 +1,10 @@
 package ocsql
 
 import (
+	"context"
+	"time"
+
+	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 )
 
@@ -23,6 +27,19 @@ type TraceOptions struct {
 	// Ping, if set to true, will enable the creation of spans on Ping requests.
 	Ping bool
 
+	// Connect, if set to true, will enable the creation of spans on the
+	// establishment (sql:connect) and closing (sql:conn_close) of
+	// connections. Connections opened by drivers not implementing
+	// driver.DriverContext, and closed connections, are only traced if
+	// AllowRoot is set since no context is available.
+	Connect bool
+
+	// Transaction, if set to true, will enable the creation of a span covering
+	// each transaction from BeginTx until Commit or Rollback. All statements
+	// executed within the transaction are parented to this span rather than
+	// to the span found in the context of the statement.
+	Transaction bool
+
 	// RowsNext, if set to true, will enable the creation of spans on RowsNext
 	// calls. This can result in many spans.
 	RowsNext bool
@@ -31,10 +48,23 @@ type TraceOptions struct {
 	// calls.
 	RowsClose bool
 
+	// Rows, if set to true, will enable the creation of a single span covering
+	// the iteration of a result set. The span records the number of rows
+	// returned, the time to the first row and the total fetch time.
+	Rows bool
+
 	// RowsAffected, if set to true, will enable the creation of spans on
 	// RowsAffected calls.
 	RowsAffected bool
 
+	// EagerRowsAffected, if set to true, will retrieve the number of rows
+	// affected by exec calls right away to record it on the exec span and as
+	// stats. This calls RowsAffected on the result of every exec, also if the
+	// application never asks for it, which costs drivers computing it lazily
+	// extra work. The value, or error, is cached so a later RowsAffected call
+	// does not hit the driver again.
+	EagerRowsAffected bool
+
 	// LastInsertID, if set to true, will enable the creation of spans on
 	// LastInsertId calls.
 	LastInsertID bool
@@ -50,9 +80,55 @@ type TraceOptions struct {
 	// This setting is a noop if the Query option is set to false.
 	QueryParams bool
 
+	// SanitizeQuery, if set to true, will replace all literals found in sql
+	// queries with placeholders before recording them in spans. IN-lists of
+	// literals and placeholders are collapsed into a single placeholder.
+	// This setting is a noop if the Query option is set to false.
+	SanitizeQuery bool
+
+	// Operation, if set to true, will parse sql queries to record their
+	// operation (e.g. SELECT) and main table as span attributes and the
+	// operation as stats tag. The query itself is not recorded.
+	Operation bool
+
+	// Dialect identifies the SQL dialect of the wrapped driver. It is used to
+	// correctly tokenize queries when sanitizing or parsing them.
+	Dialect Dialect
+
 	// DefaultAttributes will be set to each span as default.
 	DefaultAttributes []trace.Attribute
 
+	// AttributeSchema selects the keys of the span attributes. Default is
+	// AttributeSchemaLegacy.
+	AttributeSchema AttributeSchema
+
+	// Database describes the database accessed. Its fields are recorded as
+	// span attributes if the AttributeSchema is AttributeSchemaSemConv or
+	// DatabaseAttributes is enabled. Empty fields are filled from the data
+	// source name passed to the wrapped driver if its format is known.
+	Database Database
+
+	// DatabaseAttributes, if set to true, will record the Database as span
+	// attributes using the legacy keys (e.g. sql.system and sql.host) when
+	// the AttributeSchema is AttributeSchemaLegacy.
+	DatabaseAttributes bool
+
+	// DatabaseTags, if set to true, will add the database name and host as
+	// tags to the call stats. The latency and calls views hold these tags.
+	DatabaseTags bool
+
+	// AttributesFromContext, if set, is called with the context of each call
+	// and its attributes are set to the spans created for the call next to
+	// DefaultAttributes, e.g. to record the tenant or request route.
+	AttributesFromContext func(ctx context.Context) []trace.Attribute
+
+	// TagsFromContext, if set, is called with the context of each call and its
+	// tag mutators are applied to the call stats (go.sql/latency and
+	// go.sql/rows_affected). To keep the cardinality of the views in check,
+	// the tag values should come from a small fixed set. The mutators must not
+	// modify the ocsql tags.
+	TagsFromContext func(ctx context.Context) []tag.Mutator
+
 	// InstanceName identifies database.
 	InstanceName string
 
@@ -61,16 +137,169 @@ type TraceOptions struct {
 
 	// Sampler to use when creating spans.
 	Sampler trace.Sampler
+
+	// Tracer, if set, creates the spans instead of the default OpenCensus
+	// tracer. The Sampler option is not applied to custom tracers.
+	Tracer Tracer
+
+	// Recorder, if set, records the stats instead of the default OpenCensus
+	// recorder.
+	Recorder Recorder
+
+	// ErrorClassifier maps errors to the value of the GoSQLError stats tag.
+	// If not set, DefaultErrorClassifier is used.
+	ErrorClassifier ErrorClassifier
+
+	// LeakDetector, if set, keeps track of the result sets and transactions
+	// opened through the wrapped driver and reports the ones left open.
+	LeakDetector *LeakDetector
+
+	// SQLCommenter, if set, appends a comment holding the trace context to the
+	// queries passed to ExecContext, QueryContext and PrepareContext.
+	SQLCommenter *SQLCommenter
+
+	// SlowQueryThreshold is the duration after which exec, query, prepare and
+	// commit calls are delivered to SlowQuerySink.
+	SlowQueryThreshold time.Duration
+
+	// SlowQuerySink, if set, receives the calls exceeding SlowQueryThreshold
+	// regardless of span sampling.
+	SlowQuerySink SlowQuerySink
+
+	// SlowAcquireThreshold, if set, is the acquire latency after which the
+	// span of the caller is annotated with the wait for a connection. The
+	// acquire latency is only measured for calls using a context returned by
+	// WithAcquireStart.
+	SlowAcquireThreshold time.Duration
+
+	// SkipTracing, if set to true, will disable the creation of spans. Stats
+	// are still recorded. It is typically set for individual calls using the
+	// SkipTracing function.
+	SkipTracing bool
+
+	// Interceptors wrap the driver calls, in order, after the built-in
+	// interceptors recording stats and creating spans.
+	Interceptors []Interceptors
+
+	// SpanNameFormatter, if set, is consulted for the name of each span
+	// created by ocsql. It receives the default span name (e.g. "sql:query") as
+	// method and the sql query the span relates to, if any. If it returns an
+	// empty string the default span name is used.
+	SpanNameFormatter func(ctx context.Context, method, query string) string
+
+	// interceptors is the complete interceptor chain built from the options.
+	interceptors chain
+}
+
+// newTraceOptions returns the TraceOptions resulting from applying options to
+// the wrapper of parent, which is used to detect the database system.
+func newTraceOptions(parent interface{}, options ...TraceOption) TraceOptions {
+	o := TraceOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+	if o.InstanceName == "" {
+		o.InstanceName = defaultInstanceName
+	}
+	if o.Database.System == "" {
+		o.Database.System = detectDBSystem(parent, o.Dialect)
+	}
+	if o.AttributeSchema == AttributeSchemaLegacy && o.InstanceName != defaultInstanceName {
+		o.DefaultAttributes = append(o.DefaultAttributes, trace.StringAttribute(legacyKeys.instance, o.InstanceName))
+	}
+	if o.QueryParams && !o.Query {
+		o.QueryParams = false
+	}
+	o.interceptors = buildChain(o)
+	return o
 }
 
-// WithAllTraceOptions enables all available trace options.
+// contextOptionsKey is the context key of the TraceOptions to apply to
+// individual calls.
+type contextOptionsKey struct{}
+
+// WithContextOptions returns a copy of ctx holding options, which are applied
+// over the TraceOptions of the wrapped driver for the calls made with the
+// returned context, including the calls on the result sets, results and
+// transactions derived from them. Options already held by ctx are kept and
+// applied first.
+//
+// Only the options toggling spans and the recording of queries, SkipTracing
+// and DefaultAttributes take effect for individual calls; default attributes
+// are added to the ones of the wrapped driver. Like for the wrapped driver,
+// QueryParams has no effect unless Query is enabled, by the wrapped driver or
+// by options. All other options, such as the InstanceName, Tracer, Recorder
+// and Interceptors, remain those of the wrapped driver, also when provided
+// through WithAllTraceOptions or WithOptions.
+func WithContextOptions(ctx context.Context, options ...TraceOption) context.Context {
+	current, _ := ctx.Value(contextOptionsKey{}).([]TraceOption)
+	merged := make([]TraceOption, 0, len(current)+len(options))
+	merged = append(append(merged, current...), options...)
+	return context.WithValue(ctx, contextOptionsKey{}, merged)
+}
+
+// SkipTracing returns a copy of ctx disabling the creation of spans for the
+// calls made with it. Stats are still recorded.
+func SkipTracing(ctx context.Context) context.Context {
+	return WithContextOptions(ctx, func(o *TraceOptions) {
+		o.SkipTracing = true
+	})
+}
+
+// contextOptions returns options with the TraceOptions held by ctx applied.
+func contextOptions(ctx context.Context, options TraceOptions) TraceOptions {
+	if ctx == nil {
+		return options
+	}
+	overrides, _ := ctx.Value(contextOptionsKey{}).([]TraceOption)
+	if len(overrides) == 0 {
+		return options
+	}
+	// start from the wrapper options so overrides toggling individual
+	// options keep the others, and cap the slices to prevent options
+	// appending to them from modifying the wrapper options
+	applied := options
+	applied.DefaultAttributes = nil
+	applied.Interceptors = options.Interceptors[:len(options.Interceptors):len(options.Interceptors)]
+	for _, option := range overrides {
+		option(&applied)
+	}
+	options.AllowRoot = applied.AllowRoot
+	options.Ping = applied.Ping
+	options.Connect = applied.Connect
+	options.Transaction = applied.Transaction
+	options.RowsNext = applied.RowsNext
+	options.RowsClose = applied.RowsClose
+	options.Rows = applied.Rows
+	options.RowsAffected = applied.RowsAffected
+	options.EagerRowsAffected = applied.EagerRowsAffected
+	options.LastInsertID = applied.LastInsertID
+	options.Query = applied.Query
+	options.QueryParams = applied.QueryParams && applied.Query
+	options.SanitizeQuery = applied.SanitizeQuery
+	options.Operation = applied.Operation
+	options.DisableErrSkip = applied.DisableErrSkip
+	options.SkipTracing = applied.SkipTracing
+	if len(applied.DefaultAttributes) > 0 {
+		options.DefaultAttributes = append(
+			options.DefaultAttributes[:len(options.DefaultAttributes):len(options.DefaultAttributes)],
+			applied.DefaultAttributes...,
+		)
+	}
+	options.interceptors = buildChain(options)
+	return options
+}
+
+// WithAllTraceOptions enables all trace options of AllTraceOptions.
 func WithAllTraceOptions() TraceOption {
 	return func(o *TraceOptions) {
 		*o = AllTraceOptions
 	}
 }
 
-// AllTraceOptions has all tracing options enabled.
+// AllTraceOptions has all tracing options enabled, except for the Connect,
+// Transaction, Rows and Operation options. These create additional spans or
+// attributes and need to be enabled explicitly.
 var AllTraceOptions = TraceOptions{
 	AllowRoot:    true,
 	Ping:         true,
@@ -110,6 +339,23 @@ func WithPing(b bool) TraceOption {
 	}
 }
 
+// WithConnect if set to true, will enable the creation of spans on the
+// establishment and closing of connections.
+func WithConnect(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.Connect = b
+	}
+}
+
+// WithTransaction if set to true, will enable the creation of a span covering
+// each transaction from BeginTx until Commit or Rollback. All statements
+// executed within the transaction are parented to this span.
+func WithTransaction(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.Transaction = b
+	}
+}
+
 // WithRowsNext if set to true, will enable the creation of spans on RowsNext
 // calls. This can result in many spans.
 func WithRowsNext(b bool) TraceOption {
@@ -126,6 +372,15 @@ func WithRowsClose(b bool) TraceOption {
 	}
 }
 
+// WithRows if set to true, will enable the creation of a single span covering
+// the iteration of a result set. The span records the number of rows returned,
+// the time to the first row and the total fetch time.
+func WithRows(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.Rows = b
+	}
+}
+
 // WithRowsAffected if set to true, will enable the creation of spans on
 // RowsAffected calls.
 func WithRowsAffected(b bool) TraceOption {
@@ -134,6 +389,17 @@ func WithRowsAffected(b bool) TraceOption {
 	}
 }
 
+// WithEagerRowsAffected if set to true, will retrieve the number of rows
+// affected by exec calls right away to record it on the exec span and as
+// stats. This forces a RowsAffected call on the result of every exec. The
+// value, or error, is cached so a later RowsAffected call does not hit the
+// driver again.
+func WithEagerRowsAffected(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.EagerRowsAffected = b
+	}
+}
+
 // WithLastInsertID if set to true, will enable the creation of spans on
 // LastInsertId calls.
 func WithLastInsertID(b bool) TraceOption {
@@ -154,13 +420,41 @@ func WithQuery(b bool) TraceOption {
 // WithQueryParams if set to true, will enable recording of parameters used
 // with parametrized queries. Only allow this if it is safe to have
 // parameters recorded with respect to security.
-// This setting is a noop if the Query option is set to false.
+// This setting is a noop if the Query option is set to false, also when
+// provided through WithContextOptions.
 func WithQueryParams(b bool) TraceOption {
 	return func(o *TraceOptions) {
 		o.QueryParams = b
 	}
 }
 
+// WithSanitizeQuery if set to true, will replace all literals found in sql
+// queries with placeholders before recording them in spans. IN-lists of
+// literals and placeholders are collapsed into a single placeholder.
+// This setting is a noop if the Query option is set to false.
+func WithSanitizeQuery(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.SanitizeQuery = b
+	}
+}
+
+// WithOperation if set to true, will parse sql queries to record their
+// operation (e.g. SELECT) and main table as span attributes and the operation
+// as stats tag. The query itself is not recorded.
+func WithOperation(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.Operation = b
+	}
+}
+
+// WithDialect sets the SQL dialect used to tokenize queries when sanitizing or
+// parsing them.
+func WithDialect(dialect Dialect) TraceOption {
+	return func(o *TraceOptions) {
+		o.Dialect = dialect
+	}
+}
+
 // WithDefaultAttributes will be set to each span as default.
 func WithDefaultAttributes(attrs ...trace.Attribute) TraceOption {
 	return func(o *TraceOptions) {
@@ -168,6 +462,55 @@ func WithDefaultAttributes(attrs ...trace.Attribute) TraceOption {
 	}
 }
 
+// WithAttributeSchema sets the keys of the span attributes recorded.
+func WithAttributeSchema(schema AttributeSchema) TraceOption {
+	return func(o *TraceOptions) {
+		o.AttributeSchema = schema
+	}
+}
+
+// WithDatabase sets the description of the database accessed, recorded as
+// span attributes. If db.System is empty it is detected from the wrapped
+// driver.
+func WithDatabase(db Database) TraceOption {
+	return func(o *TraceOptions) {
+		o.Database = db
+	}
+}
+
+// WithDatabaseAttributes if set to true, will record the Database as span
+// attributes using the legacy keys when the AttributeSchema is
+// AttributeSchemaLegacy.
+func WithDatabaseAttributes(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.DatabaseAttributes = b
+	}
+}
+
+// WithDatabaseTags if set to true, will add the database name and host as
+// tags to the call stats. The latency and calls views hold these tags.
+func WithDatabaseTags(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.DatabaseTags = b
+	}
+}
+
+// WithAttributesFromContext sets a function providing span attributes from
+// the context of each call.
+func WithAttributesFromContext(f func(ctx context.Context) []trace.Attribute) TraceOption {
+	return func(o *TraceOptions) {
+		o.AttributesFromContext = f
+	}
+}
+
+// WithTagsFromContext sets a function providing stats tag mutators from the
+// context of each call.
+func WithTagsFromContext(f func(ctx context.Context) []tag.Mutator) TraceOption {
+	return func(o *TraceOptions) {
+		o.TagsFromContext = f
+	}
+}
+
 // WithDisableErrSkip, if set to true, will suppress driver.ErrSkip errors in spans.
 func WithDisableErrSkip(b bool) TraceOption {
 	return func(o *TraceOptions) {
@@ -182,9 +525,87 @@ func WithSampler(sampler trace.Sampler) TraceOption {
 	}
 }
 
+// WithTracer sets the Tracer creating the spans.
+func WithTracer(tracer Tracer) TraceOption {
+	return func(o *TraceOptions) {
+		o.Tracer = tracer
+	}
+}
+
+// WithRecorder sets the Recorder recording the stats.
+func WithRecorder(recorder Recorder) TraceOption {
+	return func(o *TraceOptions) {
+		o.Recorder = recorder
+	}
+}
+
+// WithErrorClassifier sets the function mapping errors to the value of the
+// GoSQLError stats tag. If not set, DefaultErrorClassifier is used.
+// PostgresErrorClassifier and MySQLErrorClassifier can be used to classify
+// driver specific errors.
+func WithErrorClassifier(fn ErrorClassifier) TraceOption {
+	return func(o *TraceOptions) {
+		o.ErrorClassifier = fn
+	}
+}
+
 // WithInstanceName sets database instance name.
 func WithInstanceName(instanceName string) TraceOption {
 	return func(o *TraceOptions) {
 		o.InstanceName = instanceName
 	}
 }
+
+// WithLeakDetector sets a LeakDetector to keep track of the result sets and
+// transactions opened through the wrapped driver and report the ones left
+// open for longer than its threshold.
+func WithLeakDetector(d *LeakDetector) TraceOption {
+	return func(o *TraceOptions) {
+		o.LeakDetector = d
+	}
+}
+
+// WithSQLCommenter enables the propagation of the trace context to the
+// database by appending a sqlcommenter comment to queries.
+func WithSQLCommenter(c SQLCommenter) TraceOption {
+	return func(o *TraceOptions) {
+		o.SQLCommenter = &c
+	}
+}
+
+// WithSlowQueryThreshold delivers all exec, query, prepare and commit calls
+// taking d or longer to sink. Queries are always sanitized, arguments are only
+// included if the QueryParams option is enabled.
+func WithSlowQueryThreshold(d time.Duration, sink SlowQuerySink) TraceOption {
+	return func(o *TraceOptions) {
+		o.SlowQueryThreshold = d
+		o.SlowQuerySink = sink
+	}
+}
+
+// WithSlowAcquireThreshold annotates the span of calls which waited d or
+// longer for a connection. See WithAcquireStart.
+func WithSlowAcquireThreshold(d time.Duration) TraceOption {
+	return func(o *TraceOptions) {
+		o.SlowAcquireThreshold = d
+	}
+}
+
+// WithInterceptors registers interceptors wrapping the driver calls. They are
+// invoked in the order provided, after the built-in interceptors recording
+// stats and creating spans, so they run within the span of the call.
+func WithInterceptors(interceptors ...Interceptors) TraceOption {
+	return func(o *TraceOptions) {
+		o.Interceptors = append(o.Interceptors, interceptors...)
+	}
+}
+
+// WithSpanNameFormatter sets a function which is consulted for the name of
+// each span created by ocsql. It receives the default span name (e.g.
+// "sql:query") as method and the sql query the span relates to, if any. If it
+// returns an empty string the default span name is used.
+func WithSpanNameFormatter(fn func(ctx context.Context, method, query string) string) TraceOption {
+	return func(o *TraceOptions) {
+		o.SpanNameFormatter = fn
+	}
+}
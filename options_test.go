@@ -0,0 +1,150 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+func TestContextOptions(t *testing.T) {
+	options := newTraceOptions(nil, WithQuery(true))
+
+	if have := contextOptions(context.Background(), options); have.QueryParams || len(have.interceptors) != 2 {
+		t.Errorf("want wrapper options without context options, have: %+v", have)
+	}
+
+	ctx := WithContextOptions(context.Background(), WithQueryParams(true))
+	ctx = SkipTracing(ctx)
+	have := contextOptions(ctx, options)
+	if !have.QueryParams || !have.SkipTracing {
+		t.Errorf("want QueryParams and SkipTracing enabled, have: %+v", have)
+	}
+	if len(have.interceptors) != 1 {
+		t.Errorf("want the stats interceptors only, have: %d interceptors", len(have.interceptors))
+	}
+	if options.QueryParams || options.SkipTracing {
+		t.Error("want wrapper options left untouched")
+	}
+}
+
+func TestContextOptionsQueryParamsOnly(t *testing.T) {
+	options := newTraceOptions(nil)
+
+	ctx := WithContextOptions(context.Background(), WithQueryParams(true))
+	if have := contextOptions(ctx, options); have.QueryParams {
+		t.Error("want QueryParams without effect unless Query is enabled")
+	}
+	ctx = WithContextOptions(ctx, WithQuery(true))
+	if have := contextOptions(ctx, options); !have.Query || !have.QueryParams {
+		t.Errorf("want Query and QueryParams enabled, have: %+v", have)
+	}
+}
+
+func TestContextOptionsKeepWrapperOptions(t *testing.T) {
+	var intercepted []string
+	tracer := &recordingTracer{}
+	conn := WrapConn(stubConn{},
+		WithTracer(tracer),
+		WithInstanceName("users"),
+		WithInterceptors(Interceptors{
+			Exec: func(ctx context.Context, call *Call, next ExecFunc) (driver.Result, error) {
+				intercepted = append(intercepted, call.Query)
+				return next(ctx, call)
+			},
+		}),
+	).(*ocConn)
+
+	for _, override := range []TraceOption{
+		WithAllTraceOptions(),
+		WithOptions(TraceOptions{AllowRoot: true, Query: true}),
+	} {
+		ctx := WithContextOptions(context.Background(), override)
+		if _, err := conn.ExecContext(ctx, "UPDATE t SET a = 1", nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(intercepted) != 2 {
+		t.Errorf("want both calls intercepted, have: %v", intercepted)
+	}
+	if len(tracer.spans) != 2 {
+		t.Fatalf("want both spans created by the custom tracer, have: %d spans", len(tracer.spans))
+	}
+	for _, span := range tracer.spans {
+		if span.name != "sql:exec" || span.attrs["sql.instance"] != "users" || span.attrs["sql.query"] != "UPDATE t SET a = 1" {
+			t.Errorf("want sql:exec span of instance users with query, have: %+v", span)
+		}
+	}
+}
+
+type tenantKey struct{}
+
+func TestAttributesFromContext(t *testing.T) {
+	options := newTraceOptions(nil,
+		WithInstanceName("users"),
+		WithAttributesFromContext(func(ctx context.Context) []trace.Attribute {
+			if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+				return []trace.Attribute{trace.StringAttribute("tenant", tenant)}
+			}
+			return nil
+		}),
+	)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	want := []trace.Attribute{
+		trace.StringAttribute("sql.instance", "users"),
+		trace.StringAttribute("tenant", "acme"),
+	}
+	if have := defaultAttributes(ctx, options); !reflect.DeepEqual(have, convertAttributes(want)) {
+		t.Errorf("want: %v, have: %v", want, have)
+	}
+	if have := defaultAttributes(context.Background(), options); len(have) != 1 {
+		t.Errorf("want the default attributes only, have: %v", have)
+	}
+	if len(options.DefaultAttributes) != 1 {
+		t.Error("want default attributes left untouched")
+	}
+}
+
+func TestDatabaseTags(t *testing.T) {
+	if err := view.Register(SQLClientCallsView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(SQLClientCallsView)
+
+	conn := WrapConn(execConn{result: driver.RowsAffected(1)},
+		WithInstanceName("tagged"),
+		WithDatabase(Database{Name: "users", Host: "db.local"}),
+		WithDatabaseTags(true),
+	).(*ocConn)
+	if _, err := conn.ExecContext(context.Background(), "UPDATE t SET a = 1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := view.RetrieveData(SQLClientCallsView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[tag.Key]string{GoSQLInstance: "tagged", GoSQLDatabase: "users", GoSQLHost: "db.local"}
+	for _, row := range data {
+		tags := make(map[tag.Key]string)
+		for _, tg := range row.Tags {
+			tags[tg.Key] = tg.Value
+		}
+		if tags[GoSQLInstance] != "tagged" {
+			continue
+		}
+		for key, value := range want {
+			if tags[key] != value {
+				t.Errorf("tag %s want: %s, have: %s", key.Name(), value, tags[key])
+			}
+		}
+		return
+	}
+	t.Errorf("want calls of instance tagged, have: %v", data)
+}
@@ -0,0 +1,123 @@
+package otelsql
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"contrib.go.opencensus.io/integrations/ocsql"
+)
+
+// recorder is the ocsql Recorder recording the measurements of calls and
+// connections with OpenTelemetry instruments, named after the ocsql measures.
+type recorder struct {
+	instruments map[string]func(ctx context.Context, value float64, opt metric.MeasurementOption)
+}
+
+func newRecorder(mp metric.MeterProvider) *recorder {
+	r := &recorder{
+		instruments: make(map[string]func(context.Context, float64, metric.MeasurementOption)),
+	}
+	meter := mp.Meter(instrumentationName)
+
+	r.float64Histogram(meter, "go.sql.latency", ocsql.MeasureLatencyMs, ocsql.DefaultMillisecondsDistribution)
+	r.float64Histogram(meter, "go.sql.tx.duration", ocsql.MeasureTxDurationMs, ocsql.DefaultMillisecondsDistribution)
+	r.int64Histogram(meter, "go.sql.rows_returned", ocsql.MeasureRowsReturned, ocsql.DefaultRowsDistribution)
+	r.int64Histogram(meter, "go.sql.rows_affected", ocsql.MeasureRowsAffected, ocsql.DefaultRowsDistribution)
+	r.int64Counter(meter, "go.sql.leaked_handles", ocsql.MeasureLeakedHandles)
+	r.float64Histogram(meter, "go.sql.connections.dial_latency", ocsql.MeasureDialLatencyMs, ocsql.DefaultMillisecondsDistribution)
+	r.int64Counter(meter, "go.sql.connections.connect_errors", ocsql.MeasureConnectErrors)
+	r.float64Histogram(meter, "go.sql.connections.acquire_latency", ocsql.MeasureAcquireLatencyMs, ocsql.DefaultMillisecondsDistribution)
+	r.float64Histogram(meter, "go.sql.connections.lifetime", ocsql.MeasureConnLifetime, ocsql.DefaultSecondsDistribution)
+	r.float64Histogram(meter, "go.sql.connections.busy_time", ocsql.MeasureConnBusyTime, ocsql.DefaultSecondsDistribution)
+	r.int64Histogram(meter, "go.sql.connections.statements", ocsql.MeasureConnStatements, ocsql.DefaultRowsDistribution)
+	return r
+}
+
+func (r *recorder) float64Histogram(meter metric.Meter, name string, m *stats.Float64Measure, buckets *view.Aggregation) {
+	h, err := meter.Float64Histogram(name,
+		metric.WithDescription(m.Description()),
+		metric.WithUnit(m.Unit()),
+		metric.WithExplicitBucketBoundaries(buckets.Buckets...),
+	)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	r.instruments[m.Name()] = func(ctx context.Context, value float64, opt metric.MeasurementOption) {
+		h.Record(ctx, value, opt)
+	}
+}
+
+func (r *recorder) int64Histogram(meter metric.Meter, name string, m *stats.Int64Measure, buckets *view.Aggregation) {
+	h, err := meter.Int64Histogram(name,
+		metric.WithDescription(m.Description()),
+		metric.WithUnit(m.Unit()),
+		metric.WithExplicitBucketBoundaries(buckets.Buckets...),
+	)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	r.instruments[m.Name()] = func(ctx context.Context, value float64, opt metric.MeasurementOption) {
+		h.Record(ctx, int64(value), opt)
+	}
+}
+
+func (r *recorder) int64Counter(meter metric.Meter, name string, m *stats.Int64Measure) {
+	c, err := meter.Int64Counter(name,
+		metric.WithDescription(m.Description()),
+		metric.WithUnit(m.Unit()),
+	)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	r.instruments[m.Name()] = func(ctx context.Context, value float64, opt metric.MeasurementOption) {
+		c.Add(ctx, int64(value), opt)
+	}
+}
+
+// Record records the measurements with the instruments of their measures,
+// using the tags of ctx and mutators as attributes. This includes the
+// DatabaseTags and the tags of TagsFromContext.
+func (r *recorder) Record(ctx context.Context, mutators []tag.Mutator, measurements ...stats.Measurement) {
+	attrs, err := tagAttributes(ctx, mutators)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	opt := metric.WithAttributes(attrs...)
+	for _, m := range measurements {
+		if record, ok := r.instruments[m.Measure().Name()]; ok {
+			record(ctx, m.Value(), opt)
+		}
+	}
+}
+
+// tagAttributes returns the tags of ctx with mutators applied as attributes,
+// the way the OpenCensus stats.RecordWithTags tags measurements.
+func tagAttributes(ctx context.Context, mutators []tag.Mutator) ([]attribute.KeyValue, error) {
+	ctx, err := tag.New(ctx, mutators...)
+	if err != nil {
+		return nil, err
+	}
+	var attrs []attribute.KeyValue
+	err = decodeEach(tag.Encode(tag.FromContext(ctx)), tag.DecodeEach, func(k tag.Key, v string) {
+		attrs = append(attrs, attribute.String(k.Name(), v))
+	})
+	return attrs, err
+}
+
+// decodeEach calls fn for each tag of the encoded tag map b using decode,
+// which is tag.DecodeEach. The tag map offers no other way to iterate its
+// tags, and the metadata argument of the callback of tag.DecodeEach is of an
+// unexported type, inferred here as M.
+func decodeEach[M any](b []byte, decode func([]byte, func(tag.Key, string, M)) error, fn func(tag.Key, string)) error {
+	return decode(b, func(k tag.Key, v string, _ M) { fn(k, v) })
+}
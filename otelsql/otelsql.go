@@ -0,0 +1,118 @@
+// Package otelsql wraps database/sql drivers with the instrumentation of
+// ocsql, emitting OpenTelemetry spans and metrics instead of OpenCensus spans.
+//
+// The ocsql TraceOptions keep their meaning, allowing services to migrate from
+// ocsql one at a time:
+//
+//	driverName, err := otelsql.Register("postgres",
+//		otelsql.WithTracerProvider(tp),
+//		otelsql.WithMeterProvider(mp),
+//		otelsql.WithTraceOptions(ocsql.WithQuery(true), ocsql.WithTransaction(true)),
+//	)
+//
+// Span attributes follow the OpenTelemetry semantic conventions for database
+// clients unless a different AttributeSchema is set.
+//
+// All ocsql measures are recorded as OpenTelemetry metrics instead of
+// OpenCensus stats, named after the measures with dots as separators (e.g.
+// go.sql.latency, go.sql.rows_returned and go.sql.connections.acquire_latency)
+// and carrying the ocsql stats tags, including the DatabaseTags and the tags
+// of TagsFromContext, as attributes. RecordStats observes the statistics of
+// the connection pool.
+package otelsql
+
+import (
+	"database/sql/driver"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"contrib.go.opencensus.io/integrations/ocsql"
+)
+
+// instrumentationName identifies the tracer and meter of this package.
+const instrumentationName = "contrib.go.opencensus.io/integrations/ocsql/otelsql"
+
+// Option allows for managing otelsql configuration using functional options.
+type Option func(c *config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	traceOptions   []ocsql.TraceOption
+}
+
+// WithTracerProvider sets the TracerProvider used to create spans. Default is
+// the global TracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the MeterProvider used to record metrics. Default is
+// the global MeterProvider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithTraceOptions adds ocsql TraceOptions configuring the instrumentation.
+func WithTraceOptions(options ...ocsql.TraceOption) Option {
+	return func(c *config) {
+		c.traceOptions = append(c.traceOptions, options...)
+	}
+}
+
+// Register initializes and registers the otelsql wrapped database driver
+// identified by its driverName. On success it returns the generated
+// driverName to use when calling sql.Open.
+func Register(driverName string, options ...Option) (string, error) {
+	return RegisterWithSource(driverName, "", options...)
+}
+
+// RegisterWithSource initializes and registers the otelsql wrapped database
+// driver identified by its driverName, opening it with source to retrieve the
+// driver implementation. On success it returns the generated driverName to
+// use when calling sql.Open.
+func RegisterWithSource(driverName string, source string, options ...Option) (string, error) {
+	return ocsql.RegisterWithSource(driverName, source, wrapOptions(options)...)
+}
+
+// Wrap takes a SQL driver and wraps it with OpenTelemetry instrumentation.
+func Wrap(d driver.Driver, options ...Option) driver.Driver {
+	return ocsql.Wrap(d, wrapOptions(options)...)
+}
+
+// WrapConn allows an existing driver.Conn to be wrapped by otelsql.
+func WrapConn(c driver.Conn, options ...Option) driver.Conn {
+	return ocsql.WrapConn(c, wrapOptions(options)...)
+}
+
+// WrapConnector allows wrapping a database driver.Connector which eliminates
+// the need to register otelsql as an available driver.Driver.
+func WrapConnector(dc driver.Connector, options ...Option) driver.Connector {
+	return ocsql.WrapConnector(dc, wrapOptions(options)...)
+}
+
+// wrapOptions returns the ocsql TraceOptions creating OpenTelemetry spans and
+// recording OpenTelemetry metrics.
+func wrapOptions(options []Option) []ocsql.TraceOption {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, option := range options {
+		option(&c)
+	}
+
+	return append(
+		[]ocsql.TraceOption{ocsql.WithAttributeSchema(ocsql.AttributeSchemaSemConv)},
+		append(c.traceOptions,
+			ocsql.WithTracer(tracer{c.tracerProvider.Tracer(instrumentationName)}),
+			ocsql.WithRecorder(newRecorder(c.meterProvider)),
+		)...,
+	)
+}
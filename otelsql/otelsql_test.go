@@ -0,0 +1,247 @@
+package otelsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"contrib.go.opencensus.io/integrations/ocsql"
+)
+
+var errFailed = errors.New("failed")
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query == "FAIL" {
+		return nil, errFailed
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return []string{"id"} }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+var tenant = tag.MustNewKey("tenant")
+
+func TestWrap(t *testing.T) {
+	if err := view.Register(ocsql.SQLClientCallsView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(ocsql.SQLClientCallsView)
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	db := sql.OpenDB(dsnConnector{Wrap(fakeDriver{},
+		WithTracerProvider(tp),
+		WithMeterProvider(mp),
+		WithTraceOptions(
+			ocsql.WithAllowRoot(true),
+			ocsql.WithQuery(true),
+			ocsql.WithQueryParams(true),
+			ocsql.WithTransaction(true),
+			ocsql.WithInstanceName("users"),
+			ocsql.WithDatabase(ocsql.Database{Name: "users", Host: "db.local"}),
+			ocsql.WithDatabaseTags(true),
+			ocsql.WithTagsFromContext(func(context.Context) []tag.Mutator {
+				return []tag.Mutator{tag.Upsert(tenant, "acme")}
+			}),
+		),
+	)})
+	defer db.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	if _, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "FAIL"); err != errFailed {
+		t.Fatalf("want error %v, have: %v", errFailed, err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE users SET name = ''"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if _, ok := spans[span.Name()]; !ok {
+			spans[span.Name()] = span
+		}
+	}
+	for _, name := range []string{"sql:exec", "sql:transaction", "sql:begin_transaction", "sql:commit"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("want span %s, have: %v", name, spans)
+		}
+	}
+
+	exec := spans["sql:exec"]
+	if exec.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("want exec span parented to the caller span")
+	}
+	attrs := attribute.NewSet(exec.Attributes()...)
+	for key, want := range map[attribute.Key]attribute.Value{
+		"db.statement":         attribute.StringValue("DELETE FROM users WHERE id = ?"),
+		"db.query.parameter.0": attribute.Int64Value(1),
+		"db.system":            attribute.StringValue("other_sql"),
+		"db.instance.id":       attribute.StringValue("users"),
+	} {
+		if have, _ := attrs.Value(key); have != want {
+			t.Errorf("attribute %s want: %v, have: %v", key, want.Emit(), have.Emit())
+		}
+	}
+
+	var failed, txExec sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "sql:exec" && span.Status().Code == codes.Error {
+			failed = span
+		}
+		if span.Name() == "sql:exec" && span.Parent().SpanID() == spans["sql:transaction"].SpanContext().SpanID() {
+			txExec = span
+		}
+	}
+	if failed == nil || failed.Status().Description != errFailed.Error() {
+		t.Error("want failed exec span with error status")
+	}
+	if have := spans["sql:begin_transaction"].Parent().SpanID(); have != spans["sql:transaction"].SpanContext().SpanID() {
+		t.Error("want begin span parented to the transaction span")
+	}
+	if txExec == nil {
+		t.Error("want exec span within the transaction parented to the transaction span")
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err = reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	var latencyAttrs attribute.Set
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok {
+				for _, dp := range h.DataPoints {
+					counts[m.Name] += dp.Count
+					if m.Name == "go.sql.latency" {
+						latencyAttrs = dp.Attributes
+					}
+				}
+			}
+		}
+	}
+	if counts["go.sql.latency"] != 5 || counts["go.sql.tx.duration"] != 1 || counts["go.sql.connections.dial_latency"] != 1 {
+		t.Errorf("want 5 latency, 1 tx duration and 1 dial latency records, have: %v", counts)
+	}
+	for key, want := range map[attribute.Key]string{
+		"go_sql_instance": "users",
+		"go_sql_database": "users",
+		"go_sql_host":     "db.local",
+		"tenant":          "acme",
+	} {
+		if have, _ := latencyAttrs.Value(key); have.AsString() != want {
+			t.Errorf("latency attribute %s want: %s, have: %s", key, want, have.Emit())
+		}
+	}
+
+	rows, err := view.RetrieveData(ocsql.SQLClientCallsView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("want no OpenCensus stats recorded, have: %v", rows)
+	}
+}
+
+func TestRecordStats(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	db := sql.OpenDB(dsnConnector{fakeDriver{}})
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := RecordStats(db,
+		WithMeterProvider(mp),
+		WithTraceOptions(
+			ocsql.WithInstanceName("users"),
+			ocsql.WithDatabase(ocsql.Database{Name: "users"}),
+			ocsql.WithDatabaseTags(true),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	var rm metricdata.ResourceMetrics
+	if err = reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	gauges := map[string]metricdata.DataPoint[float64]{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if g, ok := m.Data.(metricdata.Gauge[float64]); ok && len(g.DataPoints) == 1 {
+				gauges[m.Name] = g.DataPoints[0]
+			}
+		}
+	}
+	utilization, ok := gauges["go.sql.connections.utilization"]
+	if !ok || utilization.Value != 0 {
+		t.Fatalf("want utilization of the idle pool observed, have: %v", gauges)
+	}
+	for key, want := range map[attribute.Key]string{"go_sql_instance": "users", "go_sql_database": "users"} {
+		if have, _ := utilization.Attributes.Value(key); have.AsString() != want {
+			t.Errorf("attribute %s want: %s, have: %s", key, want, have.Emit())
+		}
+	}
+}
+
+type dsnConnector struct {
+	d driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c dsnConnector) Driver() driver.Driver                        { return c.d }
@@ -0,0 +1,171 @@
+package otelsql
+
+import (
+	"context"
+	"database/sql"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"contrib.go.opencensus.io/integrations/ocsql"
+)
+
+// RecordStats registers instruments observing the connection pool statistics
+// of db, labeled with the InstanceName TraceOption and, if enabled, the
+// DatabaseTags. The statistics are collected whenever the MeterProvider reads
+// its metrics; like the ocsql StatsRecorder, the pool pressure since the
+// previous collection is derived next to the cumulative statistics. The
+// returned function unregisters the instruments.
+func RecordStats(db *sql.DB, options ...Option) (fnStop func() error, err error) {
+	c := config{meterProvider: otel.GetMeterProvider()}
+	for _, option := range options {
+		option(&c)
+	}
+	var o ocsql.TraceOptions
+	for _, option := range c.traceOptions {
+		option(&o)
+	}
+	if o.InstanceName == "" {
+		o.InstanceName = "default"
+	}
+
+	sr := ocsql.NewStatsRecorder()
+	if err = sr.Register(o.InstanceName, db, databaseTags(o)...); err != nil {
+		return nil, err
+	}
+
+	p := poolInstruments{
+		meter:     c.meterProvider.Meter(instrumentationName),
+		observers: make(map[string]func(metric.Observer, float64, metric.MeasurementOption)),
+	}
+	p.int64Gauge("go.sql.connections.open", ocsql.MeasureOpenConnections)
+	p.int64Gauge("go.sql.connections.idle", ocsql.MeasureIdleConnections)
+	p.int64Gauge("go.sql.connections.active", ocsql.MeasureActiveConnections)
+	p.int64Counter("go.sql.connections.wait_count", ocsql.MeasureWaitCount)
+	p.float64Counter("go.sql.connections.wait_duration", ocsql.MeasureWaitDuration)
+	p.int64Counter("go.sql.connections.idle_closed", ocsql.MeasureIdleClosed)
+	p.int64Counter("go.sql.connections.lifetime_closed", ocsql.MeasureLifetimeClosed)
+	p.int64Gauge("go.sql.connections.interval_wait_count", ocsql.MeasureIntervalWaitCount)
+	p.float64Gauge("go.sql.connections.avg_wait_duration", ocsql.MeasureAvgWaitDuration)
+	p.float64Gauge("go.sql.connections.utilization", ocsql.MeasureUtilization)
+	p.int64Gauge("go.sql.connections.saturated", ocsql.MeasureSaturated)
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	reg, err := p.meter.RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		sr.RecordWith(ctx, observer{Observer: obs, observers: p.observers})
+		return nil
+	}, p.observables...)
+	if err != nil {
+		return nil, err
+	}
+	return reg.Unregister, nil
+}
+
+// databaseTags returns the database tags of o if DatabaseTags is enabled.
+func databaseTags(o ocsql.TraceOptions) []tag.Mutator {
+	var tags []tag.Mutator
+	if o.DatabaseTags {
+		if o.Database.Name != "" {
+			tags = append(tags, tag.Insert(ocsql.GoSQLDatabase, o.Database.Name))
+		}
+		if o.Database.Host != "" {
+			tags = append(tags, tag.Insert(ocsql.GoSQLHost, o.Database.Host))
+		}
+	}
+	return tags
+}
+
+// poolInstruments creates the observable instruments of the connection pool
+// measures. The first error is kept and stops the creation of instruments.
+type poolInstruments struct {
+	meter       metric.Meter
+	observables []metric.Observable
+	observers   map[string]func(obs metric.Observer, value float64, opt metric.MeasurementOption)
+	err         error
+}
+
+func (p *poolInstruments) int64Gauge(name string, m *stats.Int64Measure) {
+	if p.err != nil {
+		return
+	}
+	var g metric.Int64ObservableGauge
+	if g, p.err = p.meter.Int64ObservableGauge(name,
+		metric.WithDescription(m.Description()), metric.WithUnit(m.Unit()),
+	); p.err == nil {
+		p.add(m.Name(), g, func(obs metric.Observer, value float64, opt metric.MeasurementOption) {
+			obs.ObserveInt64(g, int64(value), opt)
+		})
+	}
+}
+
+func (p *poolInstruments) float64Gauge(name string, m *stats.Float64Measure) {
+	if p.err != nil {
+		return
+	}
+	var g metric.Float64ObservableGauge
+	if g, p.err = p.meter.Float64ObservableGauge(name,
+		metric.WithDescription(m.Description()), metric.WithUnit(m.Unit()),
+	); p.err == nil {
+		p.add(m.Name(), g, func(obs metric.Observer, value float64, opt metric.MeasurementOption) {
+			obs.ObserveFloat64(g, value, opt)
+		})
+	}
+}
+
+func (p *poolInstruments) int64Counter(name string, m *stats.Int64Measure) {
+	if p.err != nil {
+		return
+	}
+	var c metric.Int64ObservableCounter
+	if c, p.err = p.meter.Int64ObservableCounter(name,
+		metric.WithDescription(m.Description()), metric.WithUnit(m.Unit()),
+	); p.err == nil {
+		p.add(m.Name(), c, func(obs metric.Observer, value float64, opt metric.MeasurementOption) {
+			obs.ObserveInt64(c, int64(value), opt)
+		})
+	}
+}
+
+func (p *poolInstruments) float64Counter(name string, m *stats.Float64Measure) {
+	if p.err != nil {
+		return
+	}
+	var c metric.Float64ObservableCounter
+	if c, p.err = p.meter.Float64ObservableCounter(name,
+		metric.WithDescription(m.Description()), metric.WithUnit(m.Unit()),
+	); p.err == nil {
+		p.add(m.Name(), c, func(obs metric.Observer, value float64, opt metric.MeasurementOption) {
+			obs.ObserveFloat64(c, value, opt)
+		})
+	}
+}
+
+func (p *poolInstruments) add(measure string, o metric.Observable, observe func(metric.Observer, float64, metric.MeasurementOption)) {
+	p.observables = append(p.observables, o)
+	p.observers[measure] = observe
+}
+
+// observer is the ocsql Recorder observing the connection pool measurements
+// with the instruments of RecordStats.
+type observer struct {
+	metric.Observer
+	observers map[string]func(metric.Observer, float64, metric.MeasurementOption)
+}
+
+func (o observer) Record(ctx context.Context, mutators []tag.Mutator, measurements ...stats.Measurement) {
+	attrs, err := tagAttributes(ctx, mutators)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	opt := metric.WithAttributes(attrs...)
+	for _, m := range measurements {
+		if observe, ok := o.observers[m.Measure().Name()]; ok {
+			observe(o.Observer, m.Value(), opt)
+		}
+	}
+}
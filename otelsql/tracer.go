@@ -0,0 +1,92 @@
+package otelsql
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"contrib.go.opencensus.io/integrations/ocsql"
+)
+
+// tracer is an ocsql.Tracer creating OpenTelemetry spans.
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t tracer) StartSpan(ctx context.Context, name string) (context.Context, ocsql.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, otelSpan{span}
+}
+
+func (tracer) FromContext(ctx context.Context) ocsql.Span {
+	span := trace.SpanFromContext(ctx)
+	if !span.SpanContext().IsValid() {
+		return nil
+	}
+	return otelSpan{span}
+}
+
+func (tracer) NewContext(parent context.Context, span ocsql.Span) context.Context {
+	s, ok := span.(otelSpan)
+	if !ok {
+		return parent
+	}
+	return trace.ContextWithSpan(parent, s.span)
+}
+
+// otelSpan adapts an OpenTelemetry span to ocsql.Span.
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SpanContext() ocsql.SpanContext {
+	sc := s.span.SpanContext()
+	return ocsql.SpanContext{
+		TraceID:    sc.TraceID(),
+		SpanID:     sc.SpanID(),
+		Sampled:    sc.IsSampled(),
+		Tracestate: sc.TraceState().String(),
+	}
+}
+
+func (s otelSpan) AddAttributes(attributes ...ocsql.Attribute) {
+	s.span.SetAttributes(convertAttrs(attributes)...)
+}
+
+func (s otelSpan) Annotate(attributes []ocsql.Attribute, str string) {
+	s.span.AddEvent(str, trace.WithAttributes(convertAttrs(attributes)...))
+}
+
+func (s otelSpan) SetStatus(status ocsql.Status) {
+	// OpenTelemetry leaves the status of successful spans unset
+	if status.Code != 0 {
+		s.span.SetStatus(codes.Error, status.Message)
+	}
+}
+
+func (s otelSpan) End() {
+	s.span.End()
+}
+
+// convertAttrs converts ocsql span attributes.
+func convertAttrs(attributes []ocsql.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attributes))
+	for _, attr := range attributes {
+		switch v := attr.Value.(type) {
+		case bool:
+			kvs = append(kvs, attribute.Bool(attr.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(attr.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(attr.Key, v))
+		case string:
+			kvs = append(kvs, attribute.String(attr.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(attr.Key, fmt.Sprintf("%v", v)))
+		}
+	}
+	return kvs
+}
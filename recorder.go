@@ -0,0 +1,34 @@
+package ocsql
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// Recorder records the stats of ocsql: the measurements of the Measure
+// variables of this package. The default Recorder records OpenCensus stats. A
+// custom Recorder, set with the Recorder TraceOption, allows for bridging the
+// stats into another metrics system.
+type Recorder interface {
+	// Record records measurements tagged with the tags of ctx and the
+	// provided mutators, like the OpenCensus stats.RecordWithTags.
+	Record(ctx context.Context, mutators []tag.Mutator, measurements ...stats.Measurement)
+}
+
+// openCensusRecorder is the default Recorder, recording OpenCensus stats.
+type openCensusRecorder struct{}
+
+func (openCensusRecorder) Record(ctx context.Context, mutators []tag.Mutator, measurements ...stats.Measurement) {
+	_ = stats.RecordWithTags(ctx, mutators, measurements...)
+}
+
+// recorderFor returns the Recorder configured by options, defaulting to the
+// OpenCensus recorder.
+func recorderFor(options TraceOptions) Recorder {
+	if options.Recorder != nil {
+		return options.Recorder
+	}
+	return openCensusRecorder{}
+}
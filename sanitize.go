@@ -0,0 +1,72 @@
+package ocsql
+
+import (
+	"bytes"
+	"strings"
+)
+
+// SanitizeQuery returns query with all string, numeric, hex and bit literals
+// replaced by a question mark and all IN-lists of literals and placeholders
+// collapsed into a single (?). Identifiers, placeholders and comments are left
+// untouched. The dialect determines how quoted sections of the query are
+// interpreted.
+func SanitizeQuery(query string, dialect Dialect) string {
+	var (
+		tokens = tokenize(query, dialect)
+		buf    bytes.Buffer
+	)
+	buf.Grow(len(query))
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.isLiteral():
+			buf.WriteByte('?')
+		case t.typ == tokenWord && strings.EqualFold(t.text, "IN"):
+			buf.WriteString(t.text)
+			if end := inListEnd(tokens, i+1); end > 0 {
+				for _, s := range tokens[i+1 : end] {
+					if s.typ == tokenSpace {
+						buf.WriteString(s.text)
+						continue
+					}
+					buf.WriteString("(?)")
+					break
+				}
+				i = end
+			}
+		default:
+			buf.WriteString(t.text)
+		}
+	}
+	return buf.String()
+}
+
+// inListEnd returns the index of the closing parenthesis of the IN-list
+// starting at tokens[start] if that list consists of literals and placeholders
+// only. It returns -1 otherwise.
+func inListEnd(tokens []token, start int) int {
+	i := start
+	for i < len(tokens) && tokens[i].typ == tokenSpace {
+		i++
+	}
+	if i >= len(tokens) || tokens[i].text != "(" {
+		return -1
+	}
+	values := 0
+	for i++; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.typ == tokenSpace || t.typ == tokenComment || t.text == ",":
+		case t.isLiteral() || t.typ == tokenPlaceholder:
+			values++
+		case t.text == ")":
+			if values == 0 {
+				return -1
+			}
+			return i
+		default:
+			return -1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,63 @@
+package ocsql
+
+import "testing"
+
+func TestSanitizeQuery(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{
+			DialectGeneric,
+			"SELECT * FROM users WHERE email = 'john@example.com' AND id = 42",
+			"SELECT * FROM users WHERE email = ? AND id = ?",
+		},
+		{
+			DialectGeneric,
+			"SELECT 'it''s', 1.5e-3, .5, 0xFF, X'DEADBEEF', B'1010' FROM t1",
+			"SELECT ?, ?, ?, ?, ?, ? FROM t1",
+		},
+		{
+			DialectGeneric,
+			"SELECT * FROM t WHERE id IN (1, 2, 3) AND name IN ('a','b')",
+			"SELECT * FROM t WHERE id IN (?) AND name IN (?)",
+		},
+		{
+			DialectGeneric,
+			"SELECT * FROM t WHERE id in ($1, $2) OR id IN (SELECT id FROM u WHERE x = 5)",
+			"SELECT * FROM t WHERE id in (?) OR id IN (SELECT id FROM u WHERE x = ?)",
+		},
+		{
+			DialectGeneric,
+			`SELECT "col1" FROM "table 2" -- comment 'kept'` + "\n/* 5 */ WHERE a = ?",
+			`SELECT "col1" FROM "table 2" -- comment 'kept'` + "\n/* 5 */ WHERE a = ?",
+		},
+		{
+			DialectPostgres,
+			"SELECT $$secret$$, $tag$it's $$ nested$tag$, E'\\'x', x::int FROM t WHERE a = $1",
+			"SELECT ?, ?, ?, x::int FROM t WHERE a = $1",
+		},
+		{
+			DialectMySQL,
+			"SELECT `id` FROM `users` WHERE name = \"john\" AND pw = 'a\\'b' # 12",
+			"SELECT `id` FROM `users` WHERE name = ? AND pw = ? # 12",
+		},
+		{
+			DialectSQLite,
+			"SELECT [my col] FROM [t] WHERE a = :a AND b = @b AND c = $c AND d = ?1 AND e = 'x'",
+			"SELECT [my col] FROM [t] WHERE a = :a AND b = @b AND c = $c AND d = ?1 AND e = ?",
+		},
+		{
+			DialectGeneric,
+			"SELECT 'unterminated",
+			"SELECT ?",
+		},
+	}
+
+	for _, test := range tests {
+		if have := SanitizeQuery(test.query, test.dialect); have != test.want {
+			t.Errorf("SanitizeQuery(%q)\nwant: %q\nhave: %q", test.query, test.want, have)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package ocsql
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// AttributeSchema selects the keys of the span attributes recorded by ocsql.
+type AttributeSchema int
+
+const (
+	// AttributeSchemaLegacy records the keys used by ocsql historically, e.g.
+	// sql.query and sql.instance. The Database is only recorded if the
+	// DatabaseAttributes TraceOption is enabled.
+	AttributeSchemaLegacy AttributeSchema = iota
+	// AttributeSchemaSemConv records the keys of the OpenTelemetry semantic
+	// conventions for database clients, e.g. db.statement and db.system.
+	// Attributes without a semantic conventions equivalent keep their ocsql
+	// keys.
+	AttributeSchemaSemConv
+)
+
+// Database describes the database accessed through the wrapped driver.
+type Database struct {
+	// System identifies the database management system, e.g. postgresql,
+	// using the values of the OpenTelemetry db.system attribute. If empty it
+	// is detected from the wrapped driver.
+	System string
+	// Name is the name of the database.
+	Name string
+	// User is the user name used to connect to the database.
+	User string
+	// Host and Port hold the network address of the database server.
+	Host string
+	Port int
+}
+
+// attributeKeys holds the span attribute keys of an AttributeSchema.
+type attributeKeys struct {
+	system, name, user, host, port, instance, statement string
+	// param prefixes the keys of the query parameters.
+	param string
+}
+
+var (
+	legacyKeys = attributeKeys{
+		system:    "sql.system",
+		name:      "sql.database",
+		user:      "sql.user",
+		host:      "sql.host",
+		port:      "sql.port",
+		instance:  "sql.instance",
+		statement: "sql.query",
+		param:     "sql.arg",
+	}
+	// The conventions defining db.statement have no keys for query
+	// parameters, so the db.query.parameter.<key> keys added by later
+	// versions of the conventions are used rather than legacy keys.
+	semConvKeys = attributeKeys{
+		system:    "db.system",
+		name:      "db.name",
+		user:      "db.user",
+		host:      "net.peer.name",
+		port:      "net.peer.port",
+		instance:  "db.instance.id",
+		statement: "db.statement",
+		param:     "db.query.parameter.",
+	}
+)
+
+func (s AttributeSchema) keys() *attributeKeys {
+	if s == AttributeSchemaSemConv {
+		return &semConvKeys
+	}
+	return &legacyKeys
+}
+
+// positionalParamKey returns the key of the query parameter at the zero based
+// index of a call using the deprecated driver interfaces.
+func (s AttributeSchema) positionalParamKey(index int) string {
+	return s.keys().param + strconv.Itoa(index)
+}
+
+// namedParamKey returns the key of the query parameter with the given name or
+// one based ordinal position.
+func (s AttributeSchema) namedParamKey(name string, ordinal int) string {
+	if s == AttributeSchemaSemConv {
+		if name == "" {
+			name = strconv.Itoa(ordinal - 1)
+		}
+		return semConvKeys.param + name
+	}
+	if name != "" {
+		return name
+	}
+	return legacyKeys.param + "." + strconv.Itoa(ordinal)
+}
+
+// databaseAttributes returns the span attributes describing the database and
+// the instance of the wrapped driver. The legacy schema records the instance
+// as one of the DefaultAttributes and the database only if the
+// DatabaseAttributes option is enabled.
+func databaseAttributes(o TraceOptions) []Attribute {
+	semConv := o.AttributeSchema == AttributeSchemaSemConv
+	if !semConv && !o.DatabaseAttributes {
+		return nil
+	}
+	keys := o.AttributeSchema.keys()
+	attrs := make([]Attribute, 0, 6)
+	if o.Database.System != "" {
+		attrs = append(attrs, stringAttr(keys.system, o.Database.System))
+	}
+	if o.Database.Name != "" {
+		attrs = append(attrs, stringAttr(keys.name, o.Database.Name))
+	}
+	if o.Database.User != "" {
+		attrs = append(attrs, stringAttr(keys.user, o.Database.User))
+	}
+	if o.Database.Host != "" {
+		attrs = append(attrs, stringAttr(keys.host, o.Database.Host))
+	}
+	if o.Database.Port != 0 {
+		attrs = append(attrs, int64Attr(keys.port, int64(o.Database.Port)))
+	}
+	if semConv && o.InstanceName != "" && o.InstanceName != defaultInstanceName {
+		attrs = append(attrs, stringAttr(keys.instance, o.InstanceName))
+	}
+	return attrs
+}
+
+// dbSystems maps the import paths of well known drivers to their db.system.
+var dbSystems = []struct {
+	pkg    string
+	system string
+}{
+	{"github.com/lib/pq", "postgresql"},
+	{"github.com/jackc/pgx", "postgresql"},
+	{"github.com/go-sql-driver/mysql", "mysql"},
+	{"github.com/mattn/go-sqlite3", "sqlite"},
+	{"modernc.org/sqlite", "sqlite"},
+	{"github.com/denisenkom/go-mssqldb", "mssql"},
+	{"github.com/microsoft/go-mssqldb", "mssql"},
+	{"github.com/godror/godror", "oracle"},
+	{"github.com/sijms/go-ora", "oracle"},
+	{"github.com/ClickHouse/clickhouse-go", "clickhouse"},
+	{"github.com/snowflakedb/gosnowflake", "snowflake"},
+	{"github.com/googleapis/go-sql-spanner", "spanner"},
+}
+
+// detectDBSystem returns the db.system of the wrapped driver, connector or
+// connection parent. If the driver is not known the system is derived from
+// dialect.
+func detectDBSystem(parent interface{}, dialect Dialect) string {
+	if system := dbSystemFromPackage(packageOf(parent)); system != "" {
+		return system
+	}
+	// connectors may be implemented outside of the driver package
+	if c, ok := parent.(interface{ Driver() driver.Driver }); ok {
+		if system := dbSystemFromPackage(packageOf(c.Driver())); system != "" {
+			return system
+		}
+	}
+	switch dialect {
+	case DialectPostgres:
+		return "postgresql"
+	case DialectMySQL:
+		return "mysql"
+	case DialectSQLite:
+		return "sqlite"
+	}
+	return "other_sql"
+}
+
+// packageOf returns the import path of the package defining the type of v.
+func packageOf(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.PkgPath()
+}
+
+// dbSystemFromPackage returns the db.system of the driver implemented in pkg
+// or an empty string if the driver is not known.
+func dbSystemFromPackage(pkg string) string {
+	for _, s := range dbSystems {
+		if pkg == s.pkg || strings.HasPrefix(pkg, s.pkg+"/") {
+			return s.system
+		}
+	}
+	return ""
+}
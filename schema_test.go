@@ -0,0 +1,100 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestDetectDBSystem(t *testing.T) {
+	tests := []struct {
+		pkg  string
+		want string
+	}{
+		{"github.com/lib/pq", "postgresql"},
+		{"github.com/jackc/pgx/v4/stdlib", "postgresql"},
+		{"github.com/go-sql-driver/mysql", "mysql"},
+		{"github.com/mattn/go-sqlite3", "sqlite"},
+		{"github.com/lib/pqx", ""},
+		{"example.com/driver", ""},
+	}
+	for _, test := range tests {
+		if have := dbSystemFromPackage(test.pkg); have != test.want {
+			t.Errorf("dbSystemFromPackage(%q) want: %q, have: %q", test.pkg, test.want, have)
+		}
+	}
+
+	if have := detectDBSystem(&ocDriver{}, DialectMySQL); have != "mysql" {
+		t.Errorf("want system derived from dialect, have: %q", have)
+	}
+	if have := detectDBSystem(nil, DialectGeneric); have != "other_sql" {
+		t.Errorf("want other_sql, have: %q", have)
+	}
+}
+
+func TestAttributeSchema(t *testing.T) {
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}, {Name: "name", Ordinal: 2, Value: "x"}}
+	tests := []struct {
+		schema     AttributeSchema
+		attributes bool
+		want       []trace.Attribute
+	}{
+		{
+			AttributeSchemaLegacy,
+			false,
+			[]trace.Attribute{
+				trace.StringAttribute("sql.instance", "users"),
+				trace.StringAttribute("sql.query", "SELECT 1"),
+				trace.Int64Attribute("sql.arg.1", 1),
+				trace.StringAttribute("name", "x"),
+				trace.Int64Attribute("sql.arg0", 1),
+			},
+		},
+		{
+			AttributeSchemaLegacy,
+			true,
+			[]trace.Attribute{
+				trace.StringAttribute("sql.instance", "users"),
+				trace.StringAttribute("sql.system", "postgresql"),
+				trace.StringAttribute("sql.database", "users"),
+				trace.Int64Attribute("sql.port", 5432),
+				trace.StringAttribute("sql.query", "SELECT 1"),
+				trace.Int64Attribute("sql.arg.1", 1),
+				trace.StringAttribute("name", "x"),
+				trace.Int64Attribute("sql.arg0", 1),
+			},
+		},
+		{
+			AttributeSchemaSemConv,
+			false,
+			[]trace.Attribute{
+				trace.StringAttribute("db.system", "postgresql"),
+				trace.StringAttribute("db.name", "users"),
+				trace.Int64Attribute("net.peer.port", 5432),
+				trace.StringAttribute("db.instance.id", "users"),
+				trace.StringAttribute("db.statement", "SELECT 1"),
+				trace.Int64Attribute("db.query.parameter.0", 1),
+				trace.StringAttribute("db.query.parameter.name", "x"),
+				trace.Int64Attribute("db.query.parameter.0", 1),
+			},
+		},
+	}
+	for _, test := range tests {
+		o := newTraceOptions(nil,
+			WithAttributeSchema(test.schema),
+			WithDatabase(Database{System: "postgresql", Name: "users", Port: 5432}),
+			WithDatabaseAttributes(test.attributes),
+			WithInstanceName("users"),
+		)
+		have := defaultAttributes(context.Background(), o)
+		have = append(have, queryAttr("SELECT 1", o))
+		have = append(have, namedParamsAttr(args, o)...)
+		have = append(have, paramsAttr([]driver.Value{int64(1)}, o)...)
+		if want := convertAttributes(test.want); !reflect.DeepEqual(have, want) {
+			t.Errorf("schema %d (attributes %t)\nwant: %v\nhave: %v", test.schema, test.attributes, want, have)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"log"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// SlowQuery describes a call which exceeded the slow query threshold.
+type SlowQuery struct {
+	// Method is the SQL method called, e.g. go.sql.query.
+	Method string
+	// Query is the sanitized query. It is empty for commits.
+	Query string
+	// Args holds the query arguments if the QueryParams TraceOption is enabled.
+	Args []driver.NamedValue
+	// Start is the time the call was made.
+	Start time.Time
+	// Duration is the time the call took.
+	Duration time.Duration
+	// Err is the error returned by the call, if any.
+	Err error
+	// Instance is the InstanceName of the wrapped driver.
+	Instance string
+	// TraceID and SpanID identify the span active when the call was made.
+	TraceID trace.TraceID
+	SpanID  trace.SpanID
+	// Caller is the first frame outside of the database/sql and ocsql
+	// packages which led to the call.
+	Caller runtime.Frame
+}
+
+// SlowQuerySink receives the calls exceeding the slow query threshold.
+// RecordSlowQuery is called synchronously from the slow call and must be safe
+// for concurrent use.
+type SlowQuerySink interface {
+	RecordSlowQuery(q SlowQuery)
+}
+
+// SlowQuerySinkFunc is an adapter to allow the use of ordinary functions as
+// SlowQuerySink.
+type SlowQuerySinkFunc func(q SlowQuery)
+
+// RecordSlowQuery calls f(q).
+func (f SlowQuerySinkFunc) RecordSlowQuery(q SlowQuery) {
+	f(q)
+}
+
+// NewLogSlowQuerySink returns a SlowQuerySink writing a line per slow call to
+// logger. If logger is nil the standard logger is used.
+func NewLogSlowQuerySink(logger *log.Logger) SlowQuerySink {
+	return SlowQuerySinkFunc(func(q SlowQuery) {
+		out := log.Printf
+		if logger != nil {
+			out = logger.Printf
+		}
+		out("ocsql: slow %s on %s took %s (trace_id=%s span_id=%s caller=%s:%d err=%v): %s",
+			q.Method, q.Instance, q.Duration, q.TraceID, q.SpanID, q.Caller.File, q.Caller.Line, q.Err, q.Query,
+		)
+	})
+}
+
+// RingSlowQuerySink is a SlowQuerySink keeping the most recent slow calls in
+// memory.
+type RingSlowQuerySink struct {
+	mu      sync.Mutex
+	records []SlowQuery
+	next    int
+	full    bool
+}
+
+// NewRingSlowQuerySink returns a RingSlowQuerySink holding up to size slow
+// calls.
+func NewRingSlowQuerySink(size int) *RingSlowQuerySink {
+	if size < 1 {
+		size = 1
+	}
+	return &RingSlowQuerySink{records: make([]SlowQuery, size)}
+}
+
+// RecordSlowQuery implements SlowQuerySink.
+func (r *RingSlowQuerySink) RecordSlowQuery(q SlowQuery) {
+	r.mu.Lock()
+	r.records[r.next] = q
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+}
+
+// Records returns the slow calls held, oldest first.
+func (r *RingSlowQuerySink) Records() []SlowQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]SlowQuery(nil), r.records[:r.next]...)
+	}
+	return append(append([]SlowQuery(nil), r.records[r.next:]...), r.records[:r.next]...)
+}
+
+// ocsqlPackage is the import path prefix of the functions in this package.
+var ocsqlPackage = reflect.TypeOf(ocDriver{}).PkgPath() + "."
+
+// recordSlowQuery returns a function to be invoked on completion of a call.
+// It delivers a SlowQuery to the configured sink if the call exceeded the
+// slow query threshold.
+func recordSlowQuery(ctx context.Context, method, query string, args []driver.NamedValue, options TraceOptions) func(err error) {
+	if options.SlowQuerySink == nil {
+		return func(error) {}
+	}
+	startTime := time.Now()
+
+	return func(err error) {
+		duration := time.Since(startTime)
+		if duration < options.SlowQueryThreshold {
+			return
+		}
+
+		q := SlowQuery{
+			Method:   method,
+			Start:    startTime,
+			Duration: duration,
+			Err:      err,
+			Instance: options.InstanceName,
+			Caller:   callerFrame(),
+		}
+		if query != "" {
+			q.Query = SanitizeQuery(query, options.Dialect)
+		}
+		if options.QueryParams && len(args) > 0 {
+			q.Args = append([]driver.NamedValue(nil), args...)
+		}
+		if span := tracerFor(options).FromContext(ctx); span != nil {
+			sc := span.SpanContext()
+			q.TraceID, q.SpanID = sc.TraceID, sc.SpanID
+		}
+		options.SlowQuerySink.RecordSlowQuery(q)
+	}
+}
+
+// callerFrame returns the first frame on the stack outside of the
+// database/sql and ocsql packages.
+func callerFrame() runtime.Frame {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, ocsqlPackage) &&
+			!strings.HasPrefix(frame.Function, "database/sql.") {
+			return frame
+		}
+		if !more {
+			return runtime.Frame{}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package ocsql_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"contrib.go.opencensus.io/integrations/ocsql"
+	"contrib.go.opencensus.io/integrations/ocsql/ocsqltest"
+)
+
+func TestRingSlowQuerySink(t *testing.T) {
+	ring := ocsql.NewRingSlowQuerySink(2)
+	if records := ring.Records(); len(records) != 0 {
+		t.Fatalf("want no records, have: %+v", records)
+	}
+
+	for _, method := range []string{"go.sql.exec", "go.sql.query", "go.sql.commit"} {
+		ring.RecordSlowQuery(ocsql.SlowQuery{Method: method})
+	}
+
+	records := ring.Records()
+	if len(records) != 2 || records[0].Method != "go.sql.query" || records[1].Method != "go.sql.commit" {
+		t.Errorf("want the two most recent records oldest first, have: %+v", records)
+	}
+}
+
+// TestSlowQuery lives outside of package ocsql as the caller of slow queries
+// is the first frame outside of it.
+func TestSlowQuery(t *testing.T) {
+	const (
+		query     = "UPDATE t SET a = 'secret' WHERE b = ?"
+		threshold = 5 * time.Millisecond
+	)
+
+	tests := []struct {
+		name     string
+		latency  time.Duration
+		options  []ocsql.TraceOption
+		wantSlow bool
+		wantArgs bool
+	}{
+		{name: "fast", latency: 0, wantSlow: false},
+		{name: "threshold", latency: threshold, wantSlow: true},
+		{name: "query", latency: threshold, options: []ocsql.TraceOption{ocsql.WithQuery(true)}, wantSlow: true},
+		{name: "params", latency: threshold, options: []ocsql.TraceOption{ocsql.WithQuery(true), ocsql.WithQueryParams(true)}, wantSlow: true, wantArgs: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := ocsqltest.NewDriver(ocsqltest.Interfaces{Context: true})
+			d.Script(query, ocsqltest.Result{RowsAffected: 1, Latency: test.latency})
+
+			ring := ocsql.NewRingSlowQuerySink(10)
+			options := append([]ocsql.TraceOption{
+				ocsql.WithSlowQueryThreshold(threshold, ring), ocsql.WithInstanceName(test.name),
+			}, test.options...)
+			db := sql.OpenDB(ocsql.WrapConnector(d.Connector(), options...))
+			defer db.Close()
+
+			if _, err := db.Exec(query, 42); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			records := ring.Records()
+			if !test.wantSlow {
+				if len(records) != 0 {
+					t.Errorf("want no slow queries below the threshold, have: %+v", records)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("want a single slow query, have: %+v", records)
+			}
+			q := records[0]
+			if q.Method != "go.sql.exec" || q.Instance != test.name || q.Duration < threshold || q.Err != nil {
+				t.Errorf("want successful exec taking at least the threshold, have: %+v", q)
+			}
+			if want := "UPDATE t SET a = ? WHERE b = ?"; q.Query != want {
+				t.Errorf("want sanitized query %q, have: %q", want, q.Query)
+			}
+			if test.wantArgs != (len(q.Args) == 1) || (test.wantArgs && q.Args[0].Value != int64(42)) {
+				t.Errorf("want args only with QueryParams, have: %+v", q.Args)
+			}
+			if !strings.Contains(q.Caller.Function, "TestSlowQuery") || !strings.HasSuffix(q.Caller.File, "slow_test.go") {
+				t.Errorf("want the test as caller, have: %s %s:%d", q.Caller.Function, q.Caller.File, q.Caller.Line)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package ocsql
+
+import (
+	"context"
+	"strings"
+
+	"go.opencensus.io/trace"
+)
+
+// Tracer creates the spans recorded by ocsql. The default Tracer creates
+// OpenCensus spans. A custom Tracer, set with the Tracer TraceOption, allows
+// for recording spans in tests or bridging them into another tracing system.
+type Tracer interface {
+	// StartSpan starts a client span named name as child of the span held by
+	// ctx, if any, and returns a copy of ctx holding the new span.
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+
+	// FromContext returns the span held by ctx or nil if ctx holds no span.
+	FromContext(ctx context.Context) Span
+
+	// NewContext returns a copy of parent holding span, which was created by
+	// the Tracer.
+	NewContext(parent context.Context, span Span) context.Context
+}
+
+// Span is a span created by a Tracer. Its methods take the value types of
+// ocsql, so implementations bridging into another tracing system do not
+// depend on OpenCensus.
+type Span interface {
+	// SpanContext returns the identity of the span.
+	SpanContext() SpanContext
+
+	// AddAttributes sets attributes in the span.
+	AddAttributes(attributes ...Attribute)
+
+	// Annotate adds an annotation with attributes to the span.
+	Annotate(attributes []Attribute, str string)
+
+	// SetStatus sets the status of the span.
+	SetStatus(status Status)
+
+	// End ends the span.
+	End()
+}
+
+// Attribute is a key-value pair set on a span. Value is a bool, int64,
+// float64 or string.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Status is the status of a span. Code is one of the status codes of the
+// OpenCensus trace package, which match the gRPC codes: zero for success, any
+// other value for an error described by Message.
+type Status struct {
+	Code    int32
+	Message string
+}
+
+// SpanContext identifies a span.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+	// Tracestate holds the W3C tracestate of the span, e.g. "k1=v1,k2=v2".
+	Tracestate string
+}
+
+func stringAttr(key, value string) Attribute      { return Attribute{Key: key, Value: value} }
+func int64Attr(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+func boolAttr(key string, value bool) Attribute   { return Attribute{Key: key, Value: value} }
+
+func float64Attr(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// convertAttributes converts the OpenCensus attributes of DefaultAttributes
+// and AttributesFromContext.
+func convertAttributes(attrs []trace.Attribute) []Attribute {
+	converted := make([]Attribute, len(attrs))
+	for i, attr := range attrs {
+		converted[i] = Attribute{Key: attr.Key(), Value: attr.Value()}
+	}
+	return converted
+}
+
+// openCensusTracer is the default Tracer, creating OpenCensus spans using
+// sampler.
+type openCensusTracer struct {
+	sampler trace.Sampler
+}
+
+func (t openCensusTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := trace.StartSpan(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithSampler(t.sampler),
+	)
+	return ctx, openCensusSpan{span}
+}
+
+func (openCensusTracer) FromContext(ctx context.Context) Span {
+	if span := trace.FromContext(ctx); span != nil {
+		return openCensusSpan{span}
+	}
+	return nil
+}
+
+func (openCensusTracer) NewContext(parent context.Context, span Span) context.Context {
+	s, _ := span.(openCensusSpan)
+	return trace.NewContext(parent, s.span)
+}
+
+// openCensusSpan adapts an OpenCensus span to Span.
+type openCensusSpan struct {
+	span *trace.Span
+}
+
+func (s openCensusSpan) SpanContext() SpanContext {
+	sc := s.span.SpanContext()
+	spanContext := SpanContext{
+		TraceID: sc.TraceID,
+		SpanID:  sc.SpanID,
+		Sampled: sc.IsSampled(),
+	}
+	if sc.Tracestate != nil {
+		entries := make([]string, 0, len(sc.Tracestate.Entries()))
+		for _, e := range sc.Tracestate.Entries() {
+			entries = append(entries, e.Key+"="+e.Value)
+		}
+		spanContext.Tracestate = strings.Join(entries, ",")
+	}
+	return spanContext
+}
+
+func (s openCensusSpan) AddAttributes(attributes ...Attribute) {
+	s.span.AddAttributes(openCensusAttributes(attributes)...)
+}
+
+func (s openCensusSpan) Annotate(attributes []Attribute, str string) {
+	s.span.Annotate(openCensusAttributes(attributes), str)
+}
+
+func (s openCensusSpan) SetStatus(status Status) {
+	s.span.SetStatus(trace.Status{Code: status.Code, Message: status.Message})
+}
+
+func (s openCensusSpan) End() {
+	s.span.End()
+}
+
+// openCensusAttributes converts attributes to OpenCensus attributes.
+func openCensusAttributes(attributes []Attribute) []trace.Attribute {
+	attrs := make([]trace.Attribute, 0, len(attributes))
+	for _, attr := range attributes {
+		switch v := attr.Value.(type) {
+		case bool:
+			attrs = append(attrs, trace.BoolAttribute(attr.Key, v))
+		case int64:
+			attrs = append(attrs, trace.Int64Attribute(attr.Key, v))
+		case float64:
+			attrs = append(attrs, trace.Float64Attribute(attr.Key, v))
+		case string:
+			attrs = append(attrs, trace.StringAttribute(attr.Key, v))
+		}
+	}
+	return attrs
+}
+
+// tracerFor returns the Tracer configured by options, defaulting to the
+// OpenCensus tracer using the configured Sampler.
+func tracerFor(options TraceOptions) Tracer {
+	if options.Tracer != nil {
+		return options.Tracer
+	}
+	return openCensusTracer{sampler: options.Sampler}
+}
@@ -0,0 +1,85 @@
+package ocsql
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+type recordedSpan struct {
+	name        string
+	parent      *recordedSpan
+	attrs       map[string]interface{}
+	annotations []string
+	status      Status
+	ended       bool
+}
+
+func (s *recordedSpan) SpanContext() SpanContext { return SpanContext{} }
+func (s *recordedSpan) Annotate(_ []Attribute, str string) {
+	s.annotations = append(s.annotations, str)
+}
+func (s *recordedSpan) SetStatus(status Status) { s.status = status }
+func (s *recordedSpan) End()                    { s.ended = true }
+
+func (s *recordedSpan) AddAttributes(attributes ...Attribute) {
+	for _, attr := range attributes {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+type recordedSpanKey struct{}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := t.FromContext(ctx).(*recordedSpan)
+	span := &recordedSpan{name: name, parent: parent, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return t.NewContext(ctx, span), span
+}
+
+func (t *recordingTracer) FromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(recordedSpanKey{}).(*recordedSpan); ok {
+		return span
+	}
+	return nil
+}
+
+func (t *recordingTracer) NewContext(parent context.Context, span Span) context.Context {
+	return context.WithValue(parent, recordedSpanKey{}, span)
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	options := newTraceOptions(nil, WithRows(true), WithTracer(tracer))
+
+	rows := wrapRows(context.Background(), stubRows{}, "go.sql.query", "SELECT 1", options, nil)
+	if len(tracer.spans) != 0 {
+		t.Fatalf("want no spans without parent span, have: %d", len(tracer.spans))
+	}
+	_ = rows.Close()
+
+	ctx, parent := tracer.StartSpan(context.Background(), "parent")
+	rows = wrapRows(ctx, stubRows{}, "go.sql.query", "SELECT 1", options, nil)
+	if err := rows.Next(nil); err != errDummy {
+		t.Fatalf("want error %v, have: %v", errDummy, err)
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("want parent and rows span, have: %d spans", len(tracer.spans))
+	}
+	span := tracer.spans[1]
+	if span.name != "sql:rows" || !span.ended {
+		t.Errorf("want ended sql:rows span, have: %+v", span)
+	}
+	if span.status.Code != trace.StatusCodeUnknown || span.attrs["sql.rows_returned"] != int64(0) {
+		t.Errorf("want unknown error status and rows returned attribute, have: %+v", span)
+	}
+	if tracer.FromContext(ctx) != parent {
+		t.Error("want parent span held by context")
+	}
+}
@@ -0,0 +1,401 @@
+package ocsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+var (
+	attrMissingContext = stringAttr("ocsql.warning", "missing upstream context")
+	attrDeprecated     = stringAttr("ocsql.warning", "database driver uses deprecated features")
+)
+
+// tracingInterceptors returns the built-in interceptors creating the spans of
+// driver calls as configured by o.
+func tracingInterceptors(o TraceOptions) Interceptors {
+	tracer := tracerFor(o)
+	i := Interceptors{
+		Exec: func(ctx context.Context, call *Call, next ExecFunc) (res driver.Result, err error) {
+			if !o.AllowRoot && tracer.FromContext(ctx) == nil {
+				return next(ctx, call)
+			}
+			ctx, span := startCallSpan(ctx, "sql:exec", call, o)
+			defer func() {
+				traceRowsAffected(span, res)
+				setSpanStatus(span, o, err)
+				span.End()
+			}()
+			return next(ctx, call)
+		},
+		Query: func(ctx context.Context, call *Call, next QueryFunc) (rows driver.Rows, err error) {
+			if !o.AllowRoot && tracer.FromContext(ctx) == nil {
+				return next(ctx, call)
+			}
+			ctx, span := startCallSpan(ctx, "sql:query", call, o)
+			defer func() {
+				setSpanStatus(span, o, err)
+				span.End()
+			}()
+			return next(ctx, call)
+		},
+		Prepare: func(ctx context.Context, call *Call, next PrepareFunc) (stmt driver.Stmt, err error) {
+			if !o.AllowRoot && tracer.FromContext(ctx) == nil {
+				return next(ctx, call)
+			}
+			ctx, span := startCallSpan(ctx, "sql:prepare", call, o)
+			defer func() {
+				setSpanStatus(span, o, err)
+				span.End()
+			}()
+			return next(ctx, call)
+		},
+		Tx: func(ctx context.Context, call *Call, next TxFunc) (tx driver.Tx, err error) {
+			if call.Method == "go.sql.begin" {
+				return traceBegin(ctx, call, next, o)
+			}
+			if !o.AllowRoot && tracer.FromContext(ctx) == nil {
+				return next(ctx, call)
+			}
+			name := "sql:commit"
+			if call.Method == "go.sql.rollback" {
+				name = "sql:rollback"
+			}
+			ctx, span := startSpan(ctx, name, "", o)
+			defer func() {
+				setSpanStatus(span, o, err)
+				span.End()
+			}()
+			return next(ctx, call)
+		},
+	}
+
+	if o.Ping {
+		i.Ping = func(ctx context.Context, call *Call, next PingFunc) (err error) {
+			if !o.AllowRoot && tracer.FromContext(ctx) == nil {
+				return next(ctx, call)
+			}
+			ctx, span := startSpan(ctx, "sql:ping", "", o)
+			defer func() {
+				if err != nil {
+					span.SetStatus(Status{
+						Code:    trace.StatusCodeUnavailable,
+						Message: err.Error(),
+					})
+				} else {
+					span.SetStatus(Status{Code: trace.StatusCodeOK})
+				}
+				span.End()
+			}()
+			return next(ctx, call)
+		}
+	}
+
+	if o.RowsNext || o.RowsClose {
+		i.Rows = func(ctx context.Context, call *Call, dest []driver.Value, next RowsFunc) (err error) {
+			name := "sql:rows_next"
+			if call.Method == "go.sql.rows.close" {
+				if !o.RowsClose {
+					return next(ctx, call, dest)
+				}
+				name = "sql:rows_close"
+			} else if !o.RowsNext {
+				return next(ctx, call, dest)
+			}
+			if !o.AllowRoot && tracer.FromContext(ctx) == nil {
+				return next(ctx, call, dest)
+			}
+			ctx, span := startSpan(ctx, name, call.Query, o)
+			defer func() {
+				if err == io.EOF {
+					// not an error; expected to happen during iteration
+					setSpanStatus(span, o, nil)
+				} else {
+					setSpanStatus(span, o, err)
+				}
+				span.End()
+			}()
+			return next(ctx, call, dest)
+		}
+	}
+
+	if o.LastInsertID || o.RowsAffected {
+		i.Result = func(ctx context.Context, call *Call, next ResultFunc) (n int64, err error) {
+			name := "sql:rows_affected"
+			if call.Method == "go.sql.last_insert_id" {
+				if !o.LastInsertID {
+					return next(ctx, call)
+				}
+				name = "sql:last_insert_id"
+			} else if !o.RowsAffected {
+				return next(ctx, call)
+			}
+			if !o.AllowRoot && tracer.FromContext(ctx) == nil {
+				return next(ctx, call)
+			}
+			ctx, span := startSpan(ctx, name, call.Query, o)
+			defer func() {
+				setSpanStatus(span, o, err)
+				span.End()
+			}()
+			return next(ctx, call)
+		}
+	}
+
+	return i
+}
+
+// traceBegin creates the span of a go.sql.begin call and, if the Transaction
+// option is enabled, the span covering the transaction. The transaction span
+// is handed over to the caller through call.tx.
+func traceBegin(ctx context.Context, call *Call, next TxFunc, o TraceOptions) (tx driver.Tx, err error) {
+	tracer := tracerFor(o)
+	if !o.AllowRoot && tracer.FromContext(ctx) == nil {
+		return next(ctx, call)
+	}
+
+	var attrs []Attribute
+	if ctx == nil || ctx == context.TODO() {
+		ctx = context.Background()
+		attrs = append(attrs, attrMissingContext)
+	}
+	attrs = append(defaultAttributes(ctx, o), attrs...)
+	if call.deprecated != "" {
+		attrs = append(attrs, attrDeprecated, stringAttr("ocsql.deprecated", call.deprecated))
+	}
+
+	if o.Transaction {
+		state := &txState{tracer: tracer}
+		ctx, state.span = tracer.StartSpan(ctx, spanName(ctx, o, "sql:transaction", ""))
+		state.span.AddAttributes(append(
+			defaultAttributes(ctx, o),
+			stringAttr("sql.tx.isolation", isolationLevelName(call.TxOptions.Isolation)),
+			boolAttr("sql.tx.read_only", call.TxOptions.ReadOnly),
+		)...)
+		defer func() {
+			if err != nil {
+				// transaction never started
+				state.span.AddAttributes(stringAttr("sql.tx.outcome", txOutcomeError))
+				setSpanStatus(state.span, o, err)
+				state.span.End()
+			}
+		}()
+		call.tx = state
+	}
+
+	ctx, span := tracer.StartSpan(ctx, spanName(ctx, o, "sql:begin_transaction", ""))
+	if len(attrs) > 0 {
+		span.AddAttributes(attrs...)
+	}
+	defer func() {
+		setSpanStatus(span, o, err)
+		span.End()
+	}()
+	return next(ctx, call)
+}
+
+// startSpan starts a client span named after method, carrying the default
+// attributes.
+func startSpan(ctx context.Context, method, query string, o TraceOptions) (context.Context, Span) {
+	ctx, span := tracerFor(o).StartSpan(ctx, spanName(ctx, o, method, query))
+	if attrs := defaultAttributes(ctx, o); len(attrs) > 0 {
+		span.AddAttributes(attrs...)
+	}
+	return ctx, span
+}
+
+// startCallSpan starts the span of an exec, query or prepare call, carrying
+// the attributes describing the call.
+func startCallSpan(ctx context.Context, method string, call *Call, o TraceOptions) (context.Context, Span) {
+	ctx, span := tracerFor(o).StartSpan(ctx, spanName(ctx, o, method, call.Query))
+	attrs := defaultAttributes(ctx, o)
+	if call.deprecated != "" {
+		attrs = append(attrs, attrDeprecated, stringAttr("ocsql.deprecated", call.deprecated))
+	}
+	if call.missingContext {
+		attrs = append(attrs, attrMissingContext)
+	}
+	attrs = append(attrs, operationAttrs(call.Operation, call.Table)...)
+	if o.Query {
+		attrs = append(attrs, queryAttr(call.Query, o))
+		if o.QueryParams {
+			if call.deprecated != "" {
+				attrs = append(attrs, paramsAttr(values(call.Args), o)...)
+			} else {
+				attrs = append(attrs, namedParamsAttr(call.Args, o)...)
+			}
+		}
+	}
+	span.AddAttributes(attrs...)
+	return ctx, span
+}
+
+// startRowsSpan starts the span covering the iteration of a result set. It
+// returns a nil span if no span is to be created.
+func startRowsSpan(ctx context.Context, query string, o TraceOptions) (context.Context, Span) {
+	if !o.AllowRoot && tracerFor(o).FromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return startSpan(ctx, "sql:rows", query, o)
+}
+
+// startConnSpan starts the span covering the establishment or closing of a
+// connection. It returns a nil span if no span is to be created.
+func startConnSpan(ctx context.Context, name string, o TraceOptions) (context.Context, Span) {
+	if !o.Connect || (!o.AllowRoot && tracerFor(o).FromContext(ctx) == nil) {
+		return ctx, nil
+	}
+	return startSpan(ctx, name, "", o)
+}
+
+// connUsageAttrs returns the attributes describing the usage of a connection
+// over its lifetime.
+func connUsageAttrs(lifetime time.Duration, statements int64, busy time.Duration) []Attribute {
+	return []Attribute{
+		float64Attr("sql.conn.lifetime_ms", float64(lifetime.Nanoseconds())/1e6),
+		float64Attr("sql.conn.busy_ms", float64(busy.Nanoseconds())/1e6),
+		int64Attr("sql.conn.statements", statements),
+	}
+}
+
+// endRowsSpan records the iteration statistics of a result set and ends its
+// span.
+func endRowsSpan(span Span, count int64, firstRow, fetchDuration time.Duration, o TraceOptions, err error) {
+	attrs := []Attribute{
+		int64Attr("sql.rows_returned", count),
+		float64Attr("sql.fetch_duration_ms", float64(fetchDuration.Nanoseconds())/1e6),
+	}
+	if count > 0 {
+		attrs = append(attrs, float64Attr(
+			"sql.time_to_first_row_ms", float64(firstRow.Nanoseconds())/1e6,
+		))
+	}
+	span.AddAttributes(attrs...)
+	setSpanStatus(span, o, err)
+	span.End()
+}
+
+// endTxSpan ends the span covering a transaction with the provided outcome.
+func endTxSpan(state *txState, outcome string, o TraceOptions, err error) {
+	state.span.AddAttributes(
+		int64Attr("sql.tx.statements", state.statements),
+		stringAttr("sql.tx.outcome", outcome),
+	)
+	setSpanStatus(state.span, o, err)
+	state.span.End()
+}
+
+// traceRowsAffected adds the number of rows affected by an exec call to its
+// span if res holds the number retrieved by the EagerRowsAffected option.
+func traceRowsAffected(span Span, res driver.Result) {
+	if r, ok := res.(ocResult); ok && r.hasRowsAffected && r.rowsAffectedErr == nil {
+		span.AddAttributes(int64Attr("sql.rows_affected", r.rowsAffected))
+	}
+}
+
+// defaultAttributes returns the DefaultAttributes followed by the attributes
+// describing the database and the attributes provided by
+// AttributesFromContext for ctx.
+func defaultAttributes(ctx context.Context, o TraceOptions) []Attribute {
+	attrs := append(convertAttributes(o.DefaultAttributes), databaseAttributes(o)...)
+	if o.AttributesFromContext != nil {
+		attrs = append(attrs, convertAttributes(o.AttributesFromContext(ctx))...)
+	}
+	return attrs
+}
+
+func operationAttrs(operation, table string) []Attribute {
+	if operation == "" {
+		return nil
+	}
+	if table == "" {
+		return []Attribute{stringAttr("db.operation", operation)}
+	}
+	return []Attribute{
+		stringAttr("db.operation", operation),
+		stringAttr("db.sql.table", table),
+	}
+}
+
+func queryAttr(query string, options TraceOptions) Attribute {
+	if options.SanitizeQuery {
+		query = SanitizeQuery(query, options.Dialect)
+	}
+	return stringAttr(options.AttributeSchema.keys().statement, query)
+}
+
+func paramsAttr(args []driver.Value, options TraceOptions) []Attribute {
+	attrs := make([]Attribute, 0, len(args))
+	for i, arg := range args {
+		key := options.AttributeSchema.positionalParamKey(i)
+		attrs = append(attrs, argToAttr(key, arg))
+	}
+	return attrs
+}
+
+func namedParamsAttr(args []driver.NamedValue, options TraceOptions) []Attribute {
+	attrs := make([]Attribute, 0, len(args))
+	for _, arg := range args {
+		key := options.AttributeSchema.namedParamKey(arg.Name, arg.Ordinal)
+		attrs = append(attrs, argToAttr(key, arg.Value))
+	}
+	return attrs
+}
+
+func argToAttr(key string, val interface{}) Attribute {
+	switch v := val.(type) {
+	case nil:
+		return stringAttr(key, "")
+	case int64:
+		return int64Attr(key, v)
+	case float64:
+		return stringAttr(key, fmt.Sprintf("%f", v))
+	case bool:
+		return boolAttr(key, v)
+	case []byte:
+		if len(v) > 256 {
+			v = v[0:256]
+		}
+		return stringAttr(key, fmt.Sprintf("%s", v))
+	default:
+		s := fmt.Sprintf("%v", v)
+		if len(s) > 256 {
+			s = s[0:256]
+		}
+		return stringAttr(key, s)
+	}
+}
+
+func setSpanStatus(span Span, opts TraceOptions, err error) {
+	var status Status
+	switch err {
+	case nil:
+		status.Code = trace.StatusCodeOK
+		span.SetStatus(status)
+		return
+	case driver.ErrSkip:
+		status.Code = trace.StatusCodeUnimplemented
+		if opts.DisableErrSkip {
+			// Suppress driver.ErrSkip since at runtime some drivers might not have
+			// certain features, and an error would pollute many spans.
+			status.Code = trace.StatusCodeOK
+		}
+	case context.Canceled:
+		status.Code = trace.StatusCodeCancelled
+	case context.DeadlineExceeded:
+		status.Code = trace.StatusCodeDeadlineExceeded
+	case sql.ErrNoRows:
+		status.Code = trace.StatusCodeNotFound
+	case sql.ErrTxDone, errConnDone:
+		status.Code = trace.StatusCodeFailedPrecondition
+	default:
+		status.Code = trace.StatusCodeUnknown
+	}
+	status.Message = err.Error()
+	span.SetStatus(status)
+}